@@ -8,6 +8,7 @@ import (
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/core/metadata"
 	"github.com/go-gost/core/selector"
+	xctx "github.com/go-gost/x/ctx"
 )
 
 var (
@@ -89,6 +90,15 @@ func (c *Chain) Route(ctx context.Context, network, address string, opts ...chai
 		opt(&options)
 	}
 
+	// the hops of the route share the affinity hint.
+	if xctx.AffinityFromContext(ctx) == nil {
+		ctx = xctx.ContextWithAffinity(ctx, &xctx.Affinity{})
+	}
+	// the hops of the route share the visited set, so a node selected by a hop is excluded by VisitedFilter of the later hops.
+	if xctx.VisitedFromContext(ctx) == nil {
+		ctx = xctx.ContextWithVisited(ctx, &xctx.Visited{})
+	}
+
 	rt := NewRoute(ChainRouteOption(c))
 	for _, h := range c.hops {
 		node := h.Select(ctx,
@@ -0,0 +1,46 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/hop"
+	"github.com/go-gost/core/selector"
+	xs "github.com/go-gost/x/selector"
+)
+
+type testHop struct {
+	nodes    []*chain.Node
+	selector selector.Selector[*chain.Node]
+}
+
+func (h *testHop) Select(ctx context.Context, opts ...hop.SelectOption) *chain.Node {
+	return h.selector.Select(ctx, h.nodes...)
+}
+
+func TestChainRouteVisited(t *testing.T) {
+	tr := NewTransport(nil, nil)
+	nodes := []*chain.Node{
+		chain.NewNode("a", "a:8080", chain.TransportNodeOption(tr)),
+		chain.NewNode("b", "b:8080", chain.TransportNodeOption(tr)),
+	}
+
+	c := NewChain("chain")
+	for i := 0; i < 2; i++ {
+		c.AddHop(&testHop{
+			nodes:    nodes,
+			selector: xs.NewSelector(xs.RoundRobinStrategy[*chain.Node](), xs.VisitedFilter[*chain.Node](false)),
+		})
+	}
+
+	for i := 0; i < 4; i++ {
+		rt := c.Route(context.Background(), "tcp", "example.com:80")
+		if rt == nil || len(rt.Nodes()) != 2 {
+			t.Fatalf("route %d: expect 2 nodes, got %v", i, rt)
+		}
+		if first, second := rt.Nodes()[0].Name, rt.Nodes()[1].Name; first == second {
+			t.Errorf("route %d: node %s of hop 1 is selected again by hop 2", i, first)
+		}
+	}
+}
@@ -147,16 +147,82 @@ type AuthConfig struct {
 }
 
 type SelectorConfig struct {
-	Strategy    string        `json:"strategy"`
-	MaxFails    int           `yaml:"maxFails" json:"maxFails"`
-	FailTimeout time.Duration `yaml:"failTimeout" json:"failTimeout"`
-
-	HealthCheck        bool          `yaml:"healthCheck" json:"healthCheck"`
-	HealthCheckType    string        `yaml:"healthCheckType" json:"healthCheckType"`
-	HealthInterval     time.Duration `yaml:"healthInterval" json:"healthInterval"`
-	HealthTimeout      time.Duration `yaml:"healthTimeout" json:"healthTimeout"`
-	HealthPath         string        `yaml:"healthPath" json:"healthPath"`
-	HealthExpectStatus int           `yaml:"healthExpectStatus" json:"healthExpectStatus"`
+	Strategy          string             `json:"strategy"`
+	MaxFails          int                `yaml:"maxFails" json:"maxFails"`
+	FailTimeout       time.Duration      `yaml:"failTimeout" json:"failTimeout"`
+	FailCooldown      time.Duration      `yaml:"failCooldown" json:"failCooldown"`
+	FailHardWeight    int                `yaml:"failHardWeight" json:"failHardWeight"`
+	MaxConnsFilter    bool               `yaml:"maxConnsFilter" json:"maxConnsFilter"`
+	MaxConns          int                `yaml:"maxConns" json:"maxConns"`
+	SlowStart         time.Duration      `yaml:"slowStart" json:"slowStart"`
+	HashKey           string             `yaml:"hashKey" json:"hashKey"`
+	HashWindow        time.Duration      `yaml:"hashWindow" json:"hashWindow"`
+	HashReplicas      int                `yaml:"hashReplicas" json:"hashReplicas"`
+	MaglevTableSize   int                `yaml:"maglevTableSize" json:"maglevTableSize"`
+	TieBreak          string             `yaml:"tieBreak" json:"tieBreak"`
+	StickyTTL         time.Duration      `yaml:"stickyTTL" json:"stickyTTL"`
+	LoadFactor        float64            `yaml:"loadFactor" json:"loadFactor"`
+	EWMADecay         time.Duration      `yaml:"ewmaDecay" json:"ewmaDecay"`
+	StrictMetadata    bool               `yaml:"strictMetadata" json:"strictMetadata"`
+	Version           string             `json:"version"`
+	VersionPreference float64            `yaml:"versionPreference" json:"versionPreference"`
+	Scores            []*ScoreTermConfig `yaml:",omitempty" json:"scores,omitempty"`
+
+	// With HealthPriorityInterval, the priority label of a node scales its check interval, the higher the shorter.
+	// It is unrelated to the tier label of the node, which orders the selection with the lower tier preferred.
+	HealthCheck              bool                    `yaml:"healthCheck" json:"healthCheck"`
+	HealthCheckType          string                  `yaml:"healthCheckType" json:"healthCheckType"`
+	HealthInterval           time.Duration           `yaml:"healthInterval" json:"healthInterval"`
+	HealthTimeout            time.Duration           `yaml:"healthTimeout" json:"healthTimeout"`
+	HealthPath               string                  `yaml:"healthPath" json:"healthPath"`
+	HealthPaths              []string                `yaml:"healthPaths,omitempty" json:"healthPaths,omitempty"`
+	HealthPathMode           string                  `yaml:"healthPathMode" json:"healthPathMode"`
+	HealthExpectStatus       int                     `yaml:"healthExpectStatus" json:"healthExpectStatus"`
+	HealthExpectBody         string                  `yaml:"healthExpectBody" json:"healthExpectBody"`
+	HealthMethod             string                  `yaml:"healthMethod" json:"healthMethod"`
+	HealthHeader             map[string]string       `yaml:"healthHeader,omitempty" json:"healthHeader,omitempty"`
+	HealthFollowRedirects    bool                    `yaml:"healthFollowRedirects" json:"healthFollowRedirects"`
+	HealthMinTLSVersion      string                  `yaml:"healthMinTLSVersion" json:"healthMinTLSVersion"`
+	HealthCertExpiry         time.Duration           `yaml:"healthCertExpiry" json:"healthCertExpiry"`
+	HealthCertName           string                  `yaml:"healthCertName" json:"healthCertName"`
+	HealthIdleTimeout        time.Duration           `yaml:"healthIdleTimeout" json:"healthIdleTimeout"`
+	HealthReuseRetry         bool                    `yaml:"healthReuseRetry" json:"healthReuseRetry"`
+	HealthRetries            int                     `yaml:"healthRetries" json:"healthRetries"`
+	HealthRetryBackoff       time.Duration           `yaml:"healthRetryBackoff" json:"healthRetryBackoff"`
+	HealthPriorityInterval   bool                    `yaml:"healthPriorityInterval" json:"healthPriorityInterval"`
+	HealthBackoffMaxInterval time.Duration           `yaml:"healthBackoffMaxInterval" json:"healthBackoffMaxInterval"`
+	HealthBackoffFactor      float64                 `yaml:"healthBackoffFactor" json:"healthBackoffFactor"`
+	HealthJitter             float64                 `yaml:"healthJitter" json:"healthJitter"`
+	HealthConcurrency        int                     `yaml:"healthConcurrency" json:"healthConcurrency"`
+	HealthDependency         *HealthDependencyConfig `yaml:"healthDependency,omitempty" json:"healthDependency,omitempty"`
+	HealthGRPC               *HealthGRPCConfig       `yaml:"healthGRPC,omitempty" json:"healthGRPC,omitempty"`
+	HealthUDP                *HealthUDPConfig        `yaml:"healthUDP,omitempty" json:"healthUDP,omitempty"`
+	HealthExec               []string                `yaml:"healthExec,omitempty" json:"healthExec,omitempty"`
+	HealthHTTPS              bool                    `yaml:"healthHTTPS" json:"healthHTTPS"`
+	HealthTLS                *TLSConfig              `yaml:"healthTLS,omitempty" json:"healthTLS,omitempty"`
+}
+
+type ScoreTermConfig struct {
+	Label  string  `json:"label"`
+	Weight float64 `json:"weight"`
+	Lower  bool    `json:"lower"`
+}
+
+type HealthGRPCConfig struct {
+	Service  string `json:"service"`
+	TLS      bool   `json:"tls"`
+	Insecure bool   `json:"insecure"`
+}
+
+type HealthUDPConfig struct {
+	Payload string `json:"payload"`
+	Expect  string `json:"expect"`
+}
+
+type HealthDependencyConfig struct {
+	Path         string `json:"path"`
+	ExpectStatus int    `yaml:"expectStatus" json:"expectStatus"`
+	Degraded     bool   `json:"degraded"`
 }
 
 type AdmissionConfig struct {
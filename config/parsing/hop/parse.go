@@ -83,6 +83,10 @@ func ParseHop(cfg *config.HopConfig, log logger.Logger) (hop.Hop, error) {
 		}
 		md := metadata.NewMetadata(m)
 
+		if err := selector_parser.ValidateMetadata(cfg.Selector, v.Name, m, log); err != nil {
+			return nil, err
+		}
+
 		if v.Resolver == "" {
 			v.Resolver = cfg.Resolver
 		}
@@ -148,16 +152,16 @@ func ParseHop(cfg *config.HopConfig, log logger.Logger) (hop.Hop, error) {
 		}
 	}
 
-	sel := selector_parser.ParseNodeSelector(cfg.Selector)
-	if sel == nil {
-		sel = selector_parser.DefaultNodeSelector()
-	}
-
 	hopLogger := log.WithFields(map[string]any{
 		"kind": "hop",
 		"hop":  cfg.Name,
 	})
 
+	sel, err := selector_parser.BuildNodeSelector(cfg.Name, cfg.Selector, hopLogger)
+	if err != nil {
+		return nil, err
+	}
+
 	opts := []xhop.Option{
 		xhop.NameOption(cfg.Name),
 		xhop.NodeOption(nodes...),
@@ -167,8 +171,8 @@ func ParseHop(cfg *config.HopConfig, log logger.Logger) (hop.Hop, error) {
 		xhop.LoggerOption(hopLogger),
 	}
 
-	if hc := selector_parser.ParseHealthChecker(cfg.Selector, hopLogger); hc != nil {
-		opts = append(opts, xhop.HealthCheckerOption(hc))
+	if sel.HealthChecker() != nil {
+		opts = append(opts, xhop.HealthCheckerOption(sel))
 	}
 
 	if cfg.File != nil && cfg.File.Path != "" {
@@ -1,11 +1,19 @@
 package selector
 
 import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/core/selector"
 	"github.com/go-gost/x/config"
+	tls_util "github.com/go-gost/x/internal/util/tls"
+	"github.com/go-gost/x/metadata"
 	xs "github.com/go-gost/x/selector"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func ParseChainSelector(cfg *config.SelectorConfig) selector.Selector[chain.Chainer] {
@@ -13,28 +21,23 @@ func ParseChainSelector(cfg *config.SelectorConfig) selector.Selector[chain.Chai
 		return nil
 	}
 
-	var strategy selector.Strategy[chain.Chainer]
-	switch cfg.Strategy {
-	case "round", "rr":
-		strategy = xs.RoundRobinStrategy[chain.Chainer]()
-	case "random", "rand":
-		strategy = xs.RandomStrategy[chain.Chainer]()
-	case "fifo", "ha":
-		strategy = xs.FIFOStrategy[chain.Chainer]()
-	case "hash":
-		strategy = xs.HashStrategy[chain.Chainer]()
-	case "leastconn", "lc":
-		strategy = xs.LeastConnStrategy[chain.Chainer]()
-	case "leastlatency", "ll":
-		strategy = xs.LeastLatencyStrategy[chain.Chainer]()
-	default:
-		strategy = xs.RoundRobinStrategy[chain.Chainer]()
+	strategy := parseStrategy[chain.Chainer](cfg)
+	filters := []selector.Filter[chain.Chainer]{
+		xs.FailFilter[chain.Chainer](cfg.MaxFails, cfg.FailTimeout, xs.FailFilterCooldownOption(cfg.FailCooldown), xs.FailFilterHardFailWeightOption(cfg.FailHardWeight)),
 	}
-	return xs.NewSelector(
-		strategy,
-		xs.FailFilter[chain.Chainer](cfg.MaxFails, cfg.FailTimeout),
+	if cfg.MaxConnsFilter {
+		filters = append(filters, xs.MaxConnsFilter[chain.Chainer](cfg.MaxConns))
+	}
+	if cfg.SlowStart > 0 {
+		filters = append(filters, xs.SlowStartFilter[chain.Chainer](cfg.SlowStart))
+	}
+	filters = append(filters,
+		xs.DefaultFilter[chain.Chainer](),
+		xs.PriorityFilter[chain.Chainer](),
 		xs.BackupFilter[chain.Chainer](),
 	)
+
+	return xs.NewSelector(strategy, filters...)
 }
 
 func ParseNodeSelector(cfg *config.SelectorConfig) selector.Selector[*chain.Node] {
@@ -42,36 +45,110 @@ func ParseNodeSelector(cfg *config.SelectorConfig) selector.Selector[*chain.Node
 		return nil
 	}
 
-	var strategy selector.Strategy[*chain.Node]
+	strategy := parseStrategy[*chain.Node](cfg)
+
+	var failFilter selector.Filter[*chain.Node]
+	if cfg.HealthCheck {
+		failFilter = xs.HealthCheckFilter[*chain.Node](cfg.MaxFails)
+	} else {
+		failFilter = xs.FailFilter[*chain.Node](cfg.MaxFails, cfg.FailTimeout, xs.FailFilterCooldownOption(cfg.FailCooldown), xs.FailFilterHardFailWeightOption(cfg.FailHardWeight))
+	}
+
+	filters := []selector.Filter[*chain.Node]{
+		failFilter,
+		xs.MaintenanceFilter[*chain.Node](),
+		xs.DrainFilter[*chain.Node](),
+	}
+	if cfg.MaxConnsFilter {
+		filters = append(filters, xs.MaxConnsFilter[*chain.Node](cfg.MaxConns))
+	}
+	if cfg.SlowStart > 0 {
+		filters = append(filters, xs.SlowStartFilter[*chain.Node](cfg.SlowStart))
+	}
+	filters = append(filters,
+		xs.DefaultFilter[*chain.Node](),
+		xs.PriorityFilter[*chain.Node](),
+		xs.BackupFilter[*chain.Node](),
+	)
+
+	return xs.NewSelector(strategy, filters...)
+}
+
+func parseStrategy[T any](cfg *config.SelectorConfig) selector.Strategy[T] {
 	switch cfg.Strategy {
 	case "round", "rr":
-		strategy = xs.RoundRobinStrategy[*chain.Node]()
+		return xs.RoundRobinStrategy[T]()
 	case "random", "rand":
-		strategy = xs.RandomStrategy[*chain.Node]()
+		return xs.RandomStrategy[T]()
 	case "fifo", "ha":
-		strategy = xs.FIFOStrategy[*chain.Node]()
+		return xs.FIFOStrategy[T]()
 	case "hash":
-		strategy = xs.HashStrategy[*chain.Node]()
+		return xs.HashStrategy[T](
+			xs.StrategyHashKeyOption(xs.ParseHashKey(cfg.HashKey)),
+			xs.StrategyBoundedLoadOption(cfg.LoadFactor),
+		)
+	case "chash":
+		return xs.ConsistentHashStrategy[T](cfg.HashReplicas, xs.StrategyHashKeyOption(xs.ParseHashKey(cfg.HashKey)))
+	case "maglev":
+		return xs.MaglevStrategy[T](cfg.MaglevTableSize, xs.StrategyHashKeyOption(xs.ParseHashKey(cfg.HashKey)))
+	case "hashdst":
+		return xs.HashByDestinationStrategy[T](xs.StrategyBoundedLoadOption(cfg.LoadFactor))
+	case "hashwindow":
+		return xs.WindowHashStrategy[T](cfg.HashWindow, xs.StrategyHashKeyOption(xs.ParseHashKey(cfg.HashKey)))
+	case "sticky":
+		return xs.StickyStrategy[T](cfg.StickyTTL, xs.StrategyHashKeyOption(xs.ParseHashKey(cfg.HashKey)))
+	case "hashround", "hrr":
+		return xs.HashRoundRobinStrategy[T]()
 	case "leastconn", "lc":
-		strategy = xs.LeastConnStrategy[*chain.Node]()
+		return xs.LeastConnStrategy[T](xs.StrategyTieBreakOption(xs.TieBreakMode(strings.ToLower(cfg.TieBreak))))
+	case "p2c":
+		return xs.P2CStrategy[T]()
+	case "p2c-latency":
+		return xs.P2CLatencyStrategy[T]()
+	case "version":
+		return xs.PreferVersionStrategy[T](cfg.Version, cfg.VersionPreference)
+	case "region":
+		return xs.RegionStrategy[T]()
+	case "leastcost":
+		return xs.LeastCostStrategy[T](nil)
+	case "score":
+		var terms []xs.ScoreTerm
+		for _, term := range cfg.Scores {
+			if term == nil || term.Label == "" {
+				continue
+			}
+			terms = append(terms, xs.ScoreTerm{
+				Label:  term.Label,
+				Weight: term.Weight,
+				Lower:  term.Lower,
+			})
+		}
+		return xs.CompositeMetadataStrategy[T](terms)
 	case "leastlatency", "ll":
-		strategy = xs.LeastLatencyStrategy[*chain.Node]()
+		return xs.LeastLatencyStrategy[T](xs.StrategyTieBreakOption(xs.TieBreakMode(strings.ToLower(cfg.TieBreak))))
+	case "ewma":
+		return xs.EWMALatencyStrategy[T](cfg.EWMADecay)
+	case "weightedleastlatency", "wll":
+		return xs.WeightedLeastLatencyStrategy[T]()
 	default:
-		strategy = xs.RoundRobinStrategy[*chain.Node]()
+		return xs.RoundRobinStrategy[T]()
 	}
+}
 
-	var failFilter selector.Filter[*chain.Node]
-	if cfg.HealthCheck {
-		failFilter = xs.HealthCheckFilter[*chain.Node](cfg.MaxFails)
-	} else {
-		failFilter = xs.FailFilter[*chain.Node](cfg.MaxFails, cfg.FailTimeout)
+// ValidateMetadata checks the selector labels in the metadata of the object named name,
+// the invalid labels are logged as warnings, or returned as an error in strict mode.
+func ValidateMetadata(cfg *config.SelectorConfig, name string, m map[string]any, log logger.Logger) error {
+	err := xs.ValidateMetadata(metadata.NewMetadata(m))
+	if err == nil {
+		return nil
 	}
-
-	return xs.NewSelector(
-		strategy,
-		failFilter,
-		xs.BackupFilter[*chain.Node](),
-	)
+	if cfg != nil && cfg.StrictMetadata {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if log != nil {
+		log.Warnf("%s: %v, the default value is used", name, err)
+	}
+	return nil
 }
 
 func DefaultNodeSelector() selector.Selector[*chain.Node] {
@@ -90,25 +167,131 @@ func DefaultChainSelector() selector.Selector[chain.Chainer] {
 	)
 }
 
-func ParseHealthChecker(cfg *config.SelectorConfig, log logger.Logger) *xs.HealthChecker {
+// ParseHealthChecker parses the health checker of the selector, it is nil if the health check is disabled.
+// An error is returned if the TLS config of the health check can not be loaded,
+// rather than falling back to the default TLS config which skips the certificate verification.
+func ParseHealthChecker(cfg *config.SelectorConfig, log logger.Logger) (*xs.HealthChecker, error) {
 	if cfg == nil || !cfg.HealthCheck {
-		return nil
+		return nil, nil
 	}
 
 	var checkType xs.CheckType
 	switch cfg.HealthCheckType {
 	case "http":
 		checkType = xs.CheckTypeHTTP
+	case "tls":
+		checkType = xs.CheckTypeTLS
+	case "grpc":
+		checkType = xs.CheckTypeGRPC
+	case "udp":
+		checkType = xs.CheckTypeUDP
+	case "exec":
+		checkType = xs.CheckTypeExec
 	default:
 		checkType = xs.CheckTypeTCP
 	}
 
-	return xs.NewHealthChecker(
+	minTLSVersion, err := parseTLSVersion(cfg.HealthMinTLSVersion)
+	if err != nil {
+		return nil, fmt.Errorf("health check min tls version: %w", err)
+	}
+
+	opts := []xs.HealthCheckerOption{
 		xs.HealthCheckTypeOption(checkType),
 		xs.HealthCheckIntervalOption(cfg.HealthInterval),
 		xs.HealthCheckTimeoutOption(cfg.HealthTimeout),
 		xs.HealthCheckPathOption(cfg.HealthPath),
+		xs.HealthCheckPathsOption(cfg.HealthPaths, xs.PathMode(strings.ToLower(cfg.HealthPathMode))),
+		xs.HealthCheckHTTPSOption(cfg.HealthHTTPS),
 		xs.HealthCheckExpectStatusOption(cfg.HealthExpectStatus),
+		xs.HealthCheckExpectBodyOption(cfg.HealthExpectBody),
+		xs.HealthCheckMethodOption(cfg.HealthMethod),
+		xs.HealthCheckHeaderOption(parseHeader(cfg.HealthHeader)),
+		xs.HealthCheckFollowRedirectsOption(cfg.HealthFollowRedirects),
+		xs.HealthCheckMinTLSVersionOption(minTLSVersion),
+		xs.HealthCheckCertExpiryOption(cfg.HealthCertExpiry),
+		xs.HealthCheckCertNameOption(cfg.HealthCertName),
+		xs.HealthCheckIdleTimeoutOption(cfg.HealthIdleTimeout),
+		xs.HealthCheckReuseRetryOption(cfg.HealthReuseRetry),
+		xs.HealthCheckRetriesOption(cfg.HealthRetries, cfg.HealthRetryBackoff),
+		xs.HealthCheckPriorityIntervalOption(cfg.HealthPriorityInterval),
+		xs.HealthCheckJitterOption(cfg.HealthJitter),
+		xs.HealthCheckConcurrencyOption(cfg.HealthConcurrency),
 		xs.HealthCheckLoggerOption(log),
-	)
+	}
+	if dep := cfg.HealthDependency; dep != nil && dep.Path != "" {
+		opts = append(opts, xs.HealthCheckDependencyOption(dep.Path, dep.ExpectStatus, dep.Degraded))
+	}
+	if cfg.HealthBackoffMaxInterval > 0 || cfg.HealthBackoffFactor > 0 {
+		opts = append(opts, xs.HealthCheckBackoffOption(cfg.HealthBackoffMaxInterval, cfg.HealthBackoffFactor))
+	}
+	if g := cfg.HealthGRPC; g != nil {
+		opts = append(opts, xs.HealthCheckGRPCOption(g.Service, g.TLS, g.Insecure))
+	}
+	if u := cfg.HealthUDP; u != nil {
+		opts = append(opts, xs.HealthCheckUDPOption([]byte(u.Payload), []byte(u.Expect)))
+	}
+	if len(cfg.HealthExec) > 0 {
+		opts = append(opts, xs.HealthCheckExecOption(cfg.HealthExec...))
+	}
+	if cfg.HealthTLS != nil {
+		tlsConfig, err := tls_util.LoadClientConfig(cfg.HealthTLS)
+		if err != nil {
+			return nil, fmt.Errorf("health check tls: %w", err)
+		}
+		opts = append(opts, xs.HealthCheckTLSConfigOption(tlsConfig))
+	}
+
+	return xs.NewHealthChecker(opts...), nil
+}
+
+// BuildNodeSelector builds the node selector of the hop with its health checker as a bundle,
+// the health metrics of the checker are registered with the default Prometheus registerer.
+func BuildNodeSelector(hop string, cfg *config.SelectorConfig, log logger.Logger) (*xs.NodeSelectorBundle, error) {
+	hc, err := ParseHealthChecker(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	if err := xs.RegisterHealthMetrics(prometheus.DefaultRegisterer, hc, hop); err != nil && log != nil {
+		log.Warnf("health metrics: %v", err)
+	}
+
+	sel := ParseNodeSelector(cfg)
+	if sel == nil {
+		sel = DefaultNodeSelector()
+	}
+
+	maxFails := xs.DefaultMaxFails
+	if cfg != nil && cfg.MaxFails > 0 {
+		maxFails = cfg.MaxFails
+	}
+	return xs.NewNodeSelectorBundle(sel, hc, maxFails), nil
+}
+
+// parseTLSVersion parses the TLS version, the empty version is 0 for the default.
+func parseTLSVersion(v string) (uint16, error) {
+	switch strings.ToLower(v) {
+	case "":
+		return 0, nil
+	case strings.ToLower(tls_util.VersionTLS10):
+		return tls.VersionTLS10, nil
+	case strings.ToLower(tls_util.VersionTLS11):
+		return tls.VersionTLS11, nil
+	case strings.ToLower(tls_util.VersionTLS12):
+		return tls.VersionTLS12, nil
+	case strings.ToLower(tls_util.VersionTLS13):
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown tls version %q", v)
+}
+
+func parseHeader(m map[string]string) http.Header {
+	if len(m) == 0 {
+		return nil
+	}
+	header := make(http.Header, len(m))
+	for k, v := range m {
+		header.Set(k, v)
+	}
+	return header
 }
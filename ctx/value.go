@@ -3,6 +3,8 @@ package ctx
 import (
 	"context"
 	"net"
+	"sync"
+	"time"
 )
 
 type Context interface {
@@ -57,8 +59,11 @@ func SidFromContext(ctx context.Context) Sid {
 type (
 	// hashKey saves the hash source for Selector.
 	hashKey struct{}
-	Hash    struct {
-		Source string
+	// Hash is the hash keys of the request, Source is the key of the client,
+	// and Destination is the key of the requested object for the destination based sharding.
+	Hash struct {
+		Source      string
+		Destination string
 	}
 )
 
@@ -73,6 +78,112 @@ func HashFromContext(ctx context.Context) *Hash {
 	return nil
 }
 
+type (
+	// failPolicyKey saves the fail policy for FailFilter.
+	failPolicyKey struct{}
+	// FailPolicy overrides the thresholds of FailFilter per request,
+	// the zero fields are not overridden.
+	FailPolicy struct {
+		MaxFails    int
+		FailTimeout time.Duration
+	}
+)
+
+func ContextWithFailPolicy(ctx context.Context, policy *FailPolicy) context.Context {
+	return context.WithValue(ctx, failPolicyKey{}, policy)
+}
+
+func FailPolicyFromContext(ctx context.Context) *FailPolicy {
+	if v, _ := ctx.Value(failPolicyKey{}).(*FailPolicy); v != nil {
+		return v
+	}
+	return nil
+}
+
+type (
+	// affinityKey saves the affinity hint for the selectors in a chain.
+	affinityKey struct{}
+	// Affinity is the hint written by a selection and consumed by the later selections in a chain,
+	// Zone is the zone of the selected object, and Index is its ordinal in the candidates.
+	// Valid is false until a selection writes the hint.
+	Affinity struct {
+		Zone  string
+		Index int
+		Valid bool
+	}
+)
+
+func ContextWithAffinity(ctx context.Context, affinity *Affinity) context.Context {
+	return context.WithValue(ctx, affinityKey{}, affinity)
+}
+
+func AffinityFromContext(ctx context.Context) *Affinity {
+	if v, _ := ctx.Value(affinityKey{}).(*Affinity); v != nil {
+		return v
+	}
+	return nil
+}
+
+type (
+	// visitedKey saves the objects visited by the request for VisitedFilter.
+	visitedKey struct{}
+	// Visited is the set of the identities of the objects traversed by the request,
+	// it is safe for concurrent use.
+	Visited struct {
+		mu  sync.RWMutex
+		ids map[string]struct{}
+	}
+)
+
+func (v *Visited) Add(id string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.ids == nil {
+		v.ids = make(map[string]struct{})
+	}
+	v.ids[id] = struct{}{}
+}
+
+func (v *Visited) Contains(id string) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	_, ok := v.ids[id]
+	return ok
+}
+
+func ContextWithVisited(ctx context.Context, visited *Visited) context.Context {
+	return context.WithValue(ctx, visitedKey{}, visited)
+}
+
+func VisitedFromContext(ctx context.Context) *Visited {
+	if v, _ := ctx.Value(visitedKey{}).(*Visited); v != nil {
+		return v
+	}
+	return nil
+}
+
+type (
+	// allowListKey saves the allow list for AllowListFilter.
+	allowListKey struct{}
+	// AllowList is the set of the identities of the objects the request is restricted to.
+	AllowList map[string]struct{}
+)
+
+func ContextWithAllowList(ctx context.Context, ids ...string) context.Context {
+	allowList := make(AllowList, len(ids))
+	for _, id := range ids {
+		allowList[id] = struct{}{}
+	}
+	return context.WithValue(ctx, allowListKey{}, allowList)
+}
+
+func AllowListFromContext(ctx context.Context) AllowList {
+	v, _ := ctx.Value(allowListKey{}).(AllowList)
+	return v
+}
+
 type (
 	ClientID    string
 	clientIDKey struct{}
@@ -27,6 +27,13 @@ const (
 	MetricChainErrorsCounter metrics.MetricName = "gost_chain_errors_total"
 	// Total recorder records. Labels: host, recorder.
 	MetricRecorderRecordsCounter metrics.MetricName = "gost_recorder_records_total"
+	// Health of the checked addresses, 1 is healthy and 0 is unhealthy. Labels: hop, address.
+	// The health metrics are registered per hop by selector.RegisterHealthMetrics.
+	MetricHealthCheckHealthyGauge metrics.MetricName = "gost_selector_health_check_healthy"
+	// Health check probe duration histogram. Labels: hop, address.
+	MetricHealthCheckDurationObserver metrics.MetricName = "gost_selector_health_check_duration_seconds"
+	// Total failed health check probes. Labels: hop, address.
+	MetricHealthCheckFailuresCounter metrics.MetricName = "gost_selector_health_check_failures_total"
 )
 
 var (
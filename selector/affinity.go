@@ -0,0 +1,102 @@
+package selector
+
+import (
+	"context"
+
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/selector"
+	xctx "github.com/go-gost/x/ctx"
+	mdutil "github.com/go-gost/x/metadata/util"
+)
+
+// AffinityMode is the criteria of the affinity between the selections in a chain.
+type AffinityMode int
+
+const (
+	// AffinityZone prefers the objects in the same zone (the zone label) as the previous selection.
+	AffinityZone AffinityMode = iota
+	// AffinityIndex prefers the object at the same ordinal in the candidates as the previous selection.
+	AffinityIndex
+)
+
+type affinityStrategy[T any] struct {
+	delegate selector.Strategy[T]
+	mode     AffinityMode
+}
+
+// AffinityStrategy is a strategy for node selector, it correlates the selections in a chain.
+// The hint is carried by the xctx.Affinity in the context, which is set once per request by the caller,
+// each selection made by this strategy writes the zone and ordinal of the selected object into the hint,
+// and the later selections prefer the objects matching the hint by the mode.
+//
+// The affinity is a soft preference, the delegate strategy selects among the matching objects if any exists,
+// otherwise among all the objects. Without the hint in the context, it behaves as the delegate.
+func AffinityStrategy[T any](delegate selector.Strategy[T], mode AffinityMode) selector.Strategy[T] {
+	if delegate == nil {
+		delegate = RoundRobinStrategy[T]()
+	}
+	return &affinityStrategy[T]{
+		delegate: delegate,
+		mode:     mode,
+	}
+}
+
+func (s *affinityStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	hint := xctx.AffinityFromContext(ctx)
+	if hint == nil {
+		return s.delegate.Apply(ctx, vs...)
+	}
+
+	v = s.apply(ctx, hint, vs)
+
+	id := identity(v)
+	for i := range vs {
+		if identity(vs[i]) == id {
+			hint.Index = i
+			break
+		}
+	}
+	hint.Zone = zoneOf(v)
+	hint.Valid = true
+
+	return
+}
+
+func (s *affinityStrategy[T]) apply(ctx context.Context, hint *xctx.Affinity, vs []T) T {
+	if !hint.Valid {
+		return s.delegate.Apply(ctx, vs...)
+	}
+
+	switch s.mode {
+	case AffinityIndex:
+		if hint.Index >= 0 && hint.Index < len(vs) {
+			return vs[hint.Index]
+		}
+	default:
+		if hint.Zone == "" {
+			break
+		}
+		var matches []T
+		for _, v := range vs {
+			if zoneOf(v) == hint.Zone {
+				matches = append(matches, v)
+			}
+		}
+		if len(matches) > 0 {
+			return s.delegate.Apply(ctx, matches...)
+		}
+	}
+
+	return s.delegate.Apply(ctx, vs...)
+}
+
+func zoneOf(v any) string {
+	if md, _ := v.(metadata.Metadatable); md != nil {
+		return mdutil.GetString(md.Metadata(), labelZone)
+	}
+	return ""
+}
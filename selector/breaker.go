@@ -0,0 +1,345 @@
+package selector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+type breakerMode int
+
+const (
+	breakerClosed breakerMode = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type breakerState struct {
+	mode   breakerMode
+	fails  int
+	passes int
+	// since is the time the breaker trips open or turns half-open.
+	since time.Time
+	// trials is the number of the half-open trial selections of the current round,
+	// passes counts the successful ones.
+	trials int
+	// probes is the number of the consecutive passing active probes.
+	probes int
+	// seen is the last time the object is filtered or reported, for pruning.
+	seen time.Time
+}
+
+// breakerStaleTimeout is the time after which the state of an object neither filtered nor reported is pruned.
+const breakerStaleTimeout = 10 * time.Minute
+
+type CircuitBreakerOption func(*CircuitBreaker)
+
+// CircuitBreakerHalfOpenOption enables the half-open state of the breakers.
+// An open breaker turns half-open after openTimeout, then the object is admitted to at most maxTrials trial selections,
+// the breaker closes when maxTrials trials succeed and trips open again on a failed trial.
+// A half-open round whose trials are not all reported within openTimeout starts a new round.
+// Without the half-open state, an open breaker is closed only by the active probes (see Probe).
+func CircuitBreakerHalfOpenOption(openTimeout time.Duration, maxTrials int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.openTimeout = openTimeout
+		cb.halfOpenMax = maxTrials
+	}
+}
+
+// CircuitBreaker is the circuit breaking state of the objects, which unifies the passive breaking and the active probing.
+// The breaker of an object trips open after threshold consecutive failures reported by Report,
+// then BreakerFilter excludes the object, and the HealthChecker with HealthCheckCircuitBreakerOption
+// probes it aggressively, the breaker is closed after recovery consecutive passing probes,
+// or by the successful half-open trials with CircuitBreakerHalfOpenOption.
+//
+// The states are keyed by object identity, and the state of an object is pruned
+// once it is closed without failures or it is neither filtered nor reported for 10 minutes.
+type CircuitBreaker struct {
+	threshold   int
+	recovery    int
+	openTimeout time.Duration
+	halfOpenMax int
+	states      map[string]*breakerState
+	pruned      time.Time
+	onOpen      []func()
+	mu          sync.Mutex
+}
+
+// NewCircuitBreaker creates a CircuitBreaker, threshold and recovery default to 5 and 2.
+func NewCircuitBreaker(threshold, recovery int, opts ...CircuitBreakerOption) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if recovery <= 0 {
+		recovery = 2
+	}
+	cb := &CircuitBreaker{
+		threshold: threshold,
+		recovery:  recovery,
+		states:    make(map[string]*breakerState),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cb)
+		}
+	}
+	if cb.halfOpenMax > 0 && cb.openTimeout <= 0 {
+		cb.openTimeout = DefaultFailTimeout
+	}
+	return cb
+}
+
+// Report records the outcome of using v, the breaker trips open after threshold consecutive failures,
+// or on a failed half-open trial.
+func (cb *CircuitBreaker) Report(v any, outcome Outcome) {
+	var tripped []func()
+	now := time.Now()
+	id := identity(v)
+
+	cb.mu.Lock()
+	st := cb.state(id, now)
+	switch st.mode {
+	case breakerClosed:
+		if outcome == OutcomeSuccess {
+			// a closed breaker without failures is the default state.
+			delete(cb.states, id)
+		} else if st.fails++; st.fails >= cb.threshold {
+			st.trip(now)
+			tripped = cb.onOpen
+		}
+	case breakerHalfOpen:
+		if outcome != OutcomeSuccess {
+			st.trip(now)
+			tripped = cb.onOpen
+		} else if st.passes++; st.passes >= cb.halfOpenMax {
+			delete(cb.states, id)
+		}
+	}
+	cb.mu.Unlock()
+
+	for _, fn := range tripped {
+		fn()
+	}
+}
+
+// Probe records the result of an active probe of v, the open breaker is closed after recovery consecutive passes.
+// The probes do not trip the breaker, the failures of the probes are handled by the markers.
+func (cb *CircuitBreaker) Probe(v any, ok bool) {
+	id := identity(v)
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	st := cb.states[id]
+	if st == nil || st.mode == breakerClosed {
+		return
+	}
+	if !ok {
+		st.probes = 0
+		return
+	}
+	if st.probes++; st.probes >= cb.recovery {
+		delete(cb.states, id)
+	}
+}
+
+// Open reports whether the breaker of v is open or half-open.
+func (cb *CircuitBreaker) Open(v any) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	st := cb.states[identity(v)]
+	return st != nil && st.mode != breakerClosed
+}
+
+// admit reports whether v can be selected, the open breaker turns half-open after the open timeout,
+// and the half-open object is admitted until its trials of the round are used up.
+// It does not count the trials, see trial.
+func (cb *CircuitBreaker) admit(v any, now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.prune(now)
+
+	st := cb.states[identity(v)]
+	if st == nil {
+		return true
+	}
+	st.seen = now
+
+	switch st.mode {
+	case breakerClosed:
+		return true
+	case breakerOpen:
+		if cb.halfOpenMax <= 0 || now.Sub(st.since) < cb.openTimeout {
+			return false
+		}
+		st.halfOpen(now)
+	case breakerHalfOpen:
+		if st.trials >= cb.halfOpenMax && now.Sub(st.since) >= cb.openTimeout {
+			// the trials of the round are not all reported, start a new round.
+			st.halfOpen(now)
+		}
+	}
+	return st.trials < cb.halfOpenMax
+}
+
+// peek reports whether v can be selected as admit does, without changing the state.
+func (cb *CircuitBreaker) peek(v any, now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	st := cb.states[identity(v)]
+	if st == nil {
+		return true
+	}
+	switch st.mode {
+	case breakerOpen:
+		return cb.halfOpenMax > 0 && now.Sub(st.since) >= cb.openTimeout
+	case breakerHalfOpen:
+		return st.trials < cb.halfOpenMax || now.Sub(st.since) >= cb.openTimeout
+	}
+	return true
+}
+
+// trial counts the selection of v as a half-open trial.
+func (cb *CircuitBreaker) trial(v any) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if st := cb.states[identity(v)]; st != nil && st.mode == breakerHalfOpen {
+		st.trials++
+	}
+}
+
+// notifyOpen registers fn to be called when a breaker trips open.
+func (cb *CircuitBreaker) notifyOpen(fn func()) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.onOpen = append(cb.onOpen, fn)
+}
+
+func (cb *CircuitBreaker) state(id string, now time.Time) *breakerState {
+	cb.prune(now)
+
+	st := cb.states[id]
+	if st == nil {
+		st = &breakerState{}
+		cb.states[id] = st
+	}
+	st.seen = now
+	return st
+}
+
+// prune removes the states of the objects which are gone, it runs at most once per minute.
+func (cb *CircuitBreaker) prune(now time.Time) {
+	if now.Sub(cb.pruned) < time.Minute {
+		return
+	}
+	cb.pruned = now
+
+	for id, st := range cb.states {
+		if now.Sub(st.seen) >= breakerStaleTimeout {
+			delete(cb.states, id)
+		}
+	}
+}
+
+func (st *breakerState) trip(now time.Time) {
+	st.mode = breakerOpen
+	st.since = now
+	st.passes = 0
+	st.trials = 0
+	st.probes = 0
+}
+
+func (st *breakerState) halfOpen(now time.Time) {
+	st.mode = breakerHalfOpen
+	st.since = now
+	st.passes = 0
+	st.trials = 0
+}
+
+type breakerFilter[T any] struct {
+	cb *CircuitBreaker
+}
+
+// BreakerFilter filters the objects whose breakers are open in cb,
+// and the half-open objects whose trials are used up.
+// The outcomes reported to the selector are forwarded to cb, and the selections count as the half-open trials.
+func BreakerFilter[T any](cb *CircuitBreaker) selector.Filter[T] {
+	return &breakerFilter[T]{
+		cb: cb,
+	}
+}
+
+// CircuitBreakerFilter creates a BreakerFilter with its own CircuitBreaker,
+// which trips open after threshold consecutive failures, and turns half-open after openTimeout
+// with at most halfOpenMax trial selections (see CircuitBreakerHalfOpenOption).
+// threshold, openTimeout and halfOpenMax default to 5, 10s and 1.
+func CircuitBreakerFilter[T any](threshold int, openTimeout time.Duration, halfOpenMax int) selector.Filter[T] {
+	if halfOpenMax <= 0 {
+		halfOpenMax = 1
+	}
+	return BreakerFilter[T](NewCircuitBreaker(threshold, 0, CircuitBreakerHalfOpenOption(openTimeout, halfOpenMax)))
+}
+
+// Filter filters the objects with open breakers.
+func (f *breakerFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if f.cb == nil {
+		return vs
+	}
+
+	now := time.Now()
+	var l []T
+	for _, v := range vs {
+		if f.cb.admit(v, now) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// Peek implements Peeker interface.
+func (f *breakerFilter[T]) Peek(ctx context.Context, vs ...T) []T {
+	if f.cb == nil {
+		return vs
+	}
+
+	now := time.Now()
+	var l []T
+	for _, v := range vs {
+		if f.cb.peek(v, now) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// Selected implements SelectObserver interface, the selection of a half-open object is a trial.
+func (f *breakerFilter[T]) Selected(v T) {
+	if f.cb != nil {
+		f.cb.trial(v)
+	}
+}
+
+// Report implements Reporter interface.
+func (f *breakerFilter[T]) Report(v T, outcome Outcome, latency time.Duration) {
+	if f.cb != nil {
+		f.cb.Report(v, outcome)
+	}
+}
+
+func (f *breakerFilter[T]) ExhaustReason() (string, string) {
+	return "circuitBreaker", "all circuit breakers are open or out of half-open trials"
+}
+
+func markerOf(v any) selector.Marker {
+	if mi, _ := v.(selector.Markable); mi != nil {
+		return mi.Marker()
+	}
+	return nil
+}
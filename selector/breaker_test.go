@@ -0,0 +1,49 @@
+package selector
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	a := &testNode{id: "a"}
+	b := &testNode{id: "b"}
+	ctx := context.Background()
+
+	f := CircuitBreakerFilter[*testNode](1, time.Minute, 1).(*breakerFilter[*testNode])
+	admitted := func() bool {
+		for _, v := range f.Filter(ctx, a, b) {
+			if v == a {
+				return true
+			}
+		}
+		return false
+	}
+
+	f.Report(a, OutcomeFailure, 0)
+	if admitted() {
+		t.Fatal("the open object is admitted")
+	}
+
+	f.cb.states["a"].since = time.Now().Add(-time.Minute)
+	// the admissions without selection do not use up the trials.
+	for i := 0; i < 3; i++ {
+		if !admitted() {
+			t.Fatalf("the half-open object is not admitted #%d", i)
+		}
+	}
+
+	f.Selected(a)
+	if admitted() {
+		t.Fatal("the half-open object is admitted beyond its trials")
+	}
+
+	f.Report(a, OutcomeSuccess, 0)
+	if len(f.cb.states) != 0 {
+		t.Errorf("got %d breaker states, expected the closed state to be pruned", len(f.cb.states))
+	}
+	if !admitted() {
+		t.Error("the closed object is not admitted")
+	}
+}
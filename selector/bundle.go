@@ -0,0 +1,251 @@
+package selector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/selector"
+)
+
+// NodeHealth is the health state of a node.
+type NodeHealth struct {
+	// Healthy reports whether the node is up.
+	Healthy bool
+	// Fails is the failure count of the node marker.
+	Fails int64
+	// Passes is the number of consecutive passing health checks.
+	Passes int
+	// Degraded reports whether the node fails the dependency check.
+	Degraded bool
+	// LastCheck is the start time of the last health check.
+	LastCheck time.Time
+	// LastError is the error of the last health check, it is empty if the check passes.
+	LastError string
+	// Failures is the number of consecutive failed health checks.
+	Failures int
+	// Latency is the duration of the last health check.
+	Latency time.Duration
+}
+
+// NodeSelectorBundle bundles a node selector with its health checker,
+// it gives a single object to query and control the health of the nodes.
+//
+// The bundle implements the health checker interface of hop,
+// Start records the nodes and starts the health checker, Stop (or Close) stops it.
+// Each selection through the bundle touches the health checker for the lazy mode.
+//
+// The optional interfaces of the selector, such as Reporter, TrySelector and Ranker, are forwarded by the bundle,
+// they are no-ops (or fall back to Select) if the selector does not implement them.
+type NodeSelectorBundle struct {
+	selector.Selector[*chain.Node]
+	checker  *HealthChecker
+	maxFails int
+	nodes    []*chain.Node
+	mu       sync.RWMutex
+}
+
+func NewNodeSelectorBundle(sel selector.Selector[*chain.Node], checker *HealthChecker, maxFails int) *NodeSelectorBundle {
+	if maxFails <= 0 {
+		maxFails = DefaultMaxFails
+	}
+	return &NodeSelectorBundle{
+		Selector: sel,
+		checker:  checker,
+		maxFails: maxFails,
+	}
+}
+
+// HealthChecker returns the health checker of the bundle, it is nil if health check is disabled.
+func (b *NodeSelectorBundle) HealthChecker() *HealthChecker {
+	return b.checker
+}
+
+func (b *NodeSelectorBundle) Select(ctx context.Context, nodes ...*chain.Node) *chain.Node {
+	if b.checker != nil {
+		b.checker.Touch()
+	}
+	return b.Selector.Select(ctx, nodes...)
+}
+
+// Unwrap returns the selector of the bundle.
+func (b *NodeSelectorBundle) Unwrap() selector.Selector[*chain.Node] {
+	return b.Selector
+}
+
+func (b *NodeSelectorBundle) TrySelect(ctx context.Context, nodes ...*chain.Node) (*chain.Node, error) {
+	if b.checker != nil {
+		b.checker.Touch()
+	}
+	return TrySelect(ctx, b.Selector, nodes...)
+}
+
+func (b *NodeSelectorBundle) SelectHedge(ctx context.Context, nodes ...*chain.Node) (primary, hedge *chain.Node, ok bool) {
+	if b.checker != nil {
+		b.checker.Touch()
+	}
+	if hs, _ := b.Selector.(HedgeSelector[*chain.Node]); hs != nil {
+		return hs.SelectHedge(ctx, nodes...)
+	}
+	return b.Selector.Select(ctx, nodes...), nil, false
+}
+
+func (b *NodeSelectorBundle) Report(node *chain.Node, outcome Outcome, latency time.Duration) {
+	if r, _ := b.Selector.(Reporter[*chain.Node]); r != nil {
+		r.Report(node, outcome, latency)
+	}
+}
+
+func (b *NodeSelectorBundle) ReportError(node *chain.Node, err error, latency time.Duration) {
+	if r, _ := b.Selector.(ErrorReporter[*chain.Node]); r != nil {
+		r.ReportError(node, err, latency)
+		return
+	}
+	b.Report(node, ClassifyError(err), latency)
+}
+
+func (b *NodeSelectorBundle) Confirm(node *chain.Node) {
+	if c, _ := b.Selector.(Confirmer[*chain.Node]); c != nil {
+		c.Confirm(node)
+	}
+}
+
+func (b *NodeSelectorBundle) Cancel(node *chain.Node) {
+	if c, _ := b.Selector.(Confirmer[*chain.Node]); c != nil {
+		c.Cancel(node)
+	}
+}
+
+func (b *NodeSelectorBundle) Degraded() bool {
+	if d, _ := b.Selector.(Degradable); d != nil {
+		return d.Degraded()
+	}
+	return false
+}
+
+// Rank ranks the nodes by the selector, it is nil if the selector does not implement Ranker.
+func (b *NodeSelectorBundle) Rank(ctx context.Context, nodes ...*chain.Node) []*chain.Node {
+	if r, _ := b.Selector.(Ranker[*chain.Node]); r != nil {
+		return r.Rank(ctx, nodes...)
+	}
+	return nil
+}
+
+// ExplainNode explains the node by the selector, the node is eligible if the selector does not implement Explainer.
+func (b *NodeSelectorBundle) ExplainNode(ctx context.Context, node *chain.Node, nodes ...*chain.Node) (bool, string) {
+	if e, _ := b.Selector.(Explainer[*chain.Node]); e != nil {
+		return e.ExplainNode(ctx, node, nodes...)
+	}
+	return true, ""
+}
+
+func (b *NodeSelectorBundle) SetStrategy(strategy selector.Strategy[*chain.Node]) {
+	if ss, _ := b.Selector.(StrategySetter[*chain.Node]); ss != nil {
+		ss.SetStrategy(strategy)
+	}
+}
+
+func (b *NodeSelectorBundle) SetDrain(node *chain.Node, drain bool) {
+	if d, _ := b.Selector.(Drainer[*chain.Node]); d != nil {
+		d.SetDrain(node, drain)
+	}
+}
+
+func (b *NodeSelectorBundle) ExportState() []byte {
+	if st, _ := b.Selector.(StateTransferer); st != nil {
+		return st.ExportState()
+	}
+	return nil
+}
+
+func (b *NodeSelectorBundle) ImportState(data []byte) error {
+	if st, _ := b.Selector.(StateTransferer); st != nil {
+		return st.ImportState(data)
+	}
+	return nil
+}
+
+func (b *NodeSelectorBundle) SelectLatency() LatencyHistogram {
+	if st, _ := b.Selector.(SelectTimer); st != nil {
+		return st.SelectLatency()
+	}
+	return LatencyHistogram{}
+}
+
+func (b *NodeSelectorBundle) Start(nodes []any) {
+	var l []*chain.Node
+	for _, v := range nodes {
+		if node, _ := v.(*chain.Node); node != nil {
+			l = append(l, node)
+		}
+	}
+
+	b.mu.Lock()
+	b.nodes = l
+	b.mu.Unlock()
+
+	if b.checker != nil {
+		b.checker.Start(nodes)
+	}
+}
+
+func (b *NodeSelectorBundle) Stop() {
+	if b.checker != nil {
+		b.checker.Stop()
+	}
+}
+
+// Close stops the health checker.
+func (b *NodeSelectorBundle) Close() error {
+	b.Stop()
+	return nil
+}
+
+// HealthSummary returns the health states of the nodes keyed by node identity (the name of the node),
+// so the nodes of the same address are told apart.
+func (b *NodeSelectorBundle) HealthSummary() map[string]NodeHealth {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	m := make(map[string]NodeHealth, len(b.nodes))
+	for _, node := range b.nodes {
+		var h NodeHealth
+		if b.checker != nil {
+			h, _ = b.checker.NodeStatus(node)
+		}
+		if marker := node.Marker(); marker != nil {
+			h.Fails = marker.Count()
+		}
+		h.Healthy = h.Fails < int64(b.maxFails)
+		m[identity(node)] = h
+	}
+	return m
+}
+
+// ResetAll resets the markers of all nodes.
+func (b *NodeSelectorBundle) ResetAll() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, node := range b.nodes {
+		if marker := node.Marker(); marker != nil {
+			marker.Reset()
+		}
+	}
+}
+
+// Reset resets the marker of the node with identity id, the key of the node in HealthSummary.
+func (b *NodeSelectorBundle) Reset(id string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, node := range b.nodes {
+		if identity(node) != id {
+			continue
+		}
+		if marker := node.Marker(); marker != nil {
+			marker.Reset()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package selector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/chain"
+)
+
+func TestNodeSelectorBundleInterfaces(t *testing.T) {
+	b := NewNodeSelectorBundle(NewSelector(RoundRobinStrategy[*chain.Node]()), nil, 0)
+
+	var sel any = b
+	for name, ok := range map[string]bool{
+		"Reporter":        is[Reporter[*chain.Node]](sel),
+		"ErrorReporter":   is[ErrorReporter[*chain.Node]](sel),
+		"TrySelector":     is[TrySelector[*chain.Node]](sel),
+		"Degradable":      is[Degradable](sel),
+		"HedgeSelector":   is[HedgeSelector[*chain.Node]](sel),
+		"Ranker":          is[Ranker[*chain.Node]](sel),
+		"Explainer":       is[Explainer[*chain.Node]](sel),
+		"StrategySetter":  is[StrategySetter[*chain.Node]](sel),
+		"StateTransferer": is[StateTransferer](sel),
+		"SelectTimer":     is[SelectTimer](sel),
+		"Drainer":         is[Drainer[*chain.Node]](sel),
+		"Confirmer":       is[Confirmer[*chain.Node]](sel),
+	} {
+		if !ok {
+			t.Errorf("bundle does not implement %s", name)
+		}
+	}
+
+	nodes := []*chain.Node{
+		chain.NewNode("a", "a:1"),
+		chain.NewNode("b", "b:1"),
+	}
+	if got := b.Rank(context.Background(), nodes...); len(got) != len(nodes) {
+		t.Errorf("got %d ranked nodes, expected %d", len(got), len(nodes))
+	}
+	if ok, rejectedBy := b.ExplainNode(context.Background(), nodes[0], nodes...); !ok {
+		t.Errorf("node a is rejected by %s", rejectedBy)
+	}
+	if _, err := b.TrySelect(context.Background()); err == nil {
+		t.Error("got no error selecting from no nodes")
+	}
+}
+
+func TestNodeSelectorBundleHealthSummary(t *testing.T) {
+	hc := NewHealthChecker()
+	b := NewNodeSelectorBundle(NewSelector(RoundRobinStrategy[*chain.Node]()), hc, 1)
+
+	// the nodes of the same address with different transports.
+	a := chain.NewNode("a", "10.0.0.1:443")
+	c := chain.NewNode("c", "10.0.0.1:443")
+	b.Start([]any{a, c})
+	defer b.Stop()
+
+	now := time.Now()
+	hc.apply(a, now, time.Millisecond, nil, false)
+	hc.apply(c, now, time.Millisecond, errors.New("handshake failed"), false)
+
+	summary := b.HealthSummary()
+	if h := summary["a"]; !h.Healthy || h.LastError != "" {
+		t.Errorf("got node a %+v, expected healthy", h)
+	}
+	if h := summary["c"]; h.Healthy || h.LastError == "" || h.Failures != 1 {
+		t.Errorf("got node c %+v, expected unhealthy", h)
+	}
+
+	b.Reset("c")
+	if h := b.HealthSummary()["c"]; !h.Healthy {
+		t.Errorf("got node c %+v after reset, expected healthy", h)
+	}
+}
+
+func is[I any](v any) bool {
+	_, ok := v.(I)
+	return ok
+}
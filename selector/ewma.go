@@ -0,0 +1,163 @@
+package selector
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+type ewmaLatencyStrategy[T any] struct {
+	decay       time.Duration
+	stats       nodeStats
+	updated     map[string]time.Time
+	fingerprint uint64
+	r           *rand.Rand
+	mu          sync.Mutex
+}
+
+// EWMALatencyStrategy is a strategy for node selector.
+// It keeps the exponentially weighted moving average of the latency samples of the LatencyStater per node,
+// the weight of a sample decays with the time by exp(-t/decay) (decay defaults to 10s),
+// so a transient spike does not exile a node immediately.
+// The node with the minimum EWMA latency multiplied by its active connections plus one is selected,
+// the penalty of the in-flight requests avoids sending everything to the momentarily fastest node.
+// The nodes without latency data are scored by the mean EWMA latency of the other nodes,
+// so they are sampled while the in-flight penalty still applies to them, and the ties are broken randomly.
+//
+// The state of a node is dropped when it leaves the set of the nodes.
+func EWMALatencyStrategy[T any](decay time.Duration) selector.Strategy[T] {
+	if decay <= 0 {
+		decay = 10 * time.Second
+	}
+	return &ewmaLatencyStrategy[T]{
+		decay:   decay,
+		stats:   make(nodeStats),
+		updated: make(map[string]time.Time),
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *ewmaLatencyStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := identities(vs)
+	s.prune(ids)
+
+	now := time.Now()
+	states := make([]*NodeStats, len(vs))
+	var sum float64
+	var n int
+	for i, item := range vs {
+		st := s.stats.get(ids[i])
+		if ls, ok := any(item).(LatencyStater); ok {
+			if sample := ls.Latency(); sample > 0 {
+				s.update(ids[i], st, sample, now)
+			}
+		}
+		st.ActiveConns = 0
+		if c, ok := any(item).(Connectable); ok {
+			st.ActiveConns = c.ActiveConns()
+		}
+		if st.Latency > 0 {
+			sum += float64(st.Latency)
+			n++
+		}
+		states[i] = st
+	}
+
+	// the nodes without latency data are scored by the mean, or by the active connections alone if no data at all.
+	mean := 1.0
+	if n > 0 {
+		mean = sum / float64(n)
+	}
+
+	minScore := math.Inf(1)
+	var ties int
+	var selected string
+	for i, item := range vs {
+		st := states[i]
+		latency := mean
+		if st.Latency > 0 {
+			latency = float64(st.Latency)
+		}
+
+		score := latency * float64(st.ActiveConns+1)
+		if score < minScore {
+			minScore = score
+			v, ties, selected = item, 1, ids[i]
+		} else if score == minScore {
+			if ties++; s.r.Intn(ties) == 0 {
+				v, selected = item, ids[i]
+			}
+		}
+	}
+	s.stats.get(selected).Selections++
+
+	return
+}
+
+func (s *ewmaLatencyStrategy[T]) update(id string, st *NodeStats, sample time.Duration, now time.Time) {
+	last, ok := s.updated[id]
+	s.updated[id] = now
+	if !ok || st.Latency <= 0 {
+		st.Latency = sample
+		return
+	}
+
+	w := math.Exp(-float64(now.Sub(last)) / float64(s.decay))
+	st.Latency = time.Duration(float64(st.Latency)*w + float64(sample)*(1-w))
+}
+
+// prune drops the states of the nodes absent from ids if the set of the nodes changes.
+func (s *ewmaLatencyStrategy[T]) prune(ids []string) {
+	fingerprint := fingerprintOf(ids)
+	if fingerprint == s.fingerprint {
+		return
+	}
+	s.fingerprint = fingerprint
+
+	present := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		present[id] = true
+	}
+	for id := range s.stats {
+		if !present[id] {
+			delete(s.stats, id)
+			delete(s.updated, id)
+		}
+	}
+}
+
+func (s *ewmaLatencyStrategy[T]) Stats() map[string]NodeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats.snapshot()
+}
+
+// ExportState implements StateTransferer interface.
+func (s *ewmaLatencyStrategy[T]) ExportState() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats.export("ewmaLatency")
+}
+
+// ImportState implements StateTransferer interface.
+func (s *ewmaLatencyStrategy[T]) ImportState(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the imported states are kept until the set of the nodes is seen.
+	s.fingerprint = 0
+	return s.stats.load("ewmaLatency", data)
+}
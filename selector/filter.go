@@ -2,32 +2,88 @@ package selector
 
 import (
 	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-gost/core/metadata"
 	"github.com/go-gost/core/selector"
+	xctx "github.com/go-gost/x/ctx"
 	mdutil "github.com/go-gost/x/metadata/util"
 )
 
+type failFilterOptions struct {
+	cooldown   time.Duration
+	hardWeight int
+}
+
+type FailFilterOption func(*failFilterOptions)
+
+// FailFilterCooldownOption sets the stabilization window after an object is re-admitted,
+// the object can not be ejected again within the window, so a flapping object does not cause churn.
+// Note that a genuinely broken object stays in rotation for the window, it is disabled by default.
+func FailFilterCooldownOption(d time.Duration) FailFilterOption {
+	return func(opts *failFilterOptions) {
+		opts.cooldown = d
+	}
+}
+
+// FailFilterHardFailWeightOption sets the number of the failures a hard failure (see OutcomeHardFailure)
+// counts as towards the max fails, for example a refused connection ejects the object at once with the weight of max fails.
+// The failures are unweighted by default. It applies to the failures reported to the selector.
+func FailFilterHardFailWeightOption(n int) FailFilterOption {
+	return func(opts *failFilterOptions) {
+		opts.hardWeight = n
+	}
+}
+
 type failFilter[T any] struct {
 	maxFails    int
 	failTimeout time.Duration
+	options     failFilterOptions
+	// ejected objects and the time of re-admission of the objects, for the cooldown.
+	ejected  map[string]bool
+	admitted map[string]time.Time
+	mu       sync.Mutex
 }
 
 // FailFilter filters the dead objects.
 // An object is marked as dead if its failed count is greater than MaxFails.
-func FailFilter[T any](maxFails int, timeout time.Duration) selector.Filter[T] {
-	return &failFilter[T]{
+//
+// The thresholds are taken in order of precedence from
+// the fail policy in context (see xctx.ContextWithFailPolicy), the object metadata and the filter defaults.
+func FailFilter[T any](maxFails int, timeout time.Duration, opts ...FailFilterOption) selector.Filter[T] {
+	f := &failFilter[T]{
 		maxFails:    maxFails,
 		failTimeout: timeout,
+		ejected:     make(map[string]bool),
+		admitted:    make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&f.options)
+		}
 	}
+	return f
 }
 
 // Filter filters dead objects.
 func (f *failFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	return f.filter(ctx, vs, false)
+}
+
+// Peek implements Peeker interface, the cooldown is applied without tracking the ejections and re-admissions.
+func (f *failFilter[T]) Peek(ctx context.Context, vs ...T) []T {
+	return f.filter(ctx, vs, true)
+}
+
+func (f *failFilter[T]) filter(ctx context.Context, vs []T, peek bool) []T {
 	if len(vs) <= 1 {
 		return vs
 	}
+	policy := xctx.FailPolicyFromContext(ctx)
+
 	var l []T
 	for _, v := range vs {
 		maxFails := f.maxFails
@@ -42,6 +98,14 @@ func (f *failFilter[T]) Filter(ctx context.Context, vs ...T) []T {
 				}
 			}
 		}
+		if policy != nil {
+			if policy.MaxFails > 0 {
+				maxFails = policy.MaxFails
+			}
+			if policy.FailTimeout > 0 {
+				failTimeout = policy.FailTimeout
+			}
+		}
 		if maxFails <= 0 {
 			maxFails = 1
 		}
@@ -51,8 +115,9 @@ func (f *failFilter[T]) Filter(ctx context.Context, vs ...T) []T {
 
 		if mi, _ := any(v).(selector.Markable); mi != nil {
 			if marker := mi.Marker(); marker != nil {
-				if marker.Count() < int64(maxFails) ||
-					time.Since(marker.Time()) >= failTimeout {
+				alive := marker.Count() < int64(maxFails) ||
+					time.Since(marker.Time()) >= failTimeout
+				if f.cooldown(v, alive, peek) {
 					l = append(l, v)
 				}
 				continue
@@ -63,6 +128,57 @@ func (f *failFilter[T]) Filter(ctx context.Context, vs ...T) []T {
 	return l
 }
 
+// FailWeight implements FailWeigher interface.
+func (f *failFilter[T]) FailWeight(outcome Outcome) int {
+	if outcome == OutcomeHardFailure && f.options.hardWeight > 1 {
+		return f.options.hardWeight
+	}
+	return 1
+}
+
+// cooldown tracks the ejection and re-admission of v, and reports whether v is kept,
+// a dead object is kept if it is within the cooldown after its re-admission.
+// If peek is true, nothing is tracked.
+func (f *failFilter[T]) cooldown(v T, alive bool, peek bool) bool {
+	if f.options.cooldown <= 0 {
+		return alive
+	}
+
+	id := identity(v)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if peek {
+		if alive {
+			return true
+		}
+		t, ok := f.admitted[id]
+		return ok && time.Since(t) < f.options.cooldown
+	}
+
+	if alive {
+		if f.ejected[id] {
+			delete(f.ejected, id)
+			f.admitted[id] = time.Now()
+		}
+		return true
+	}
+
+	if t, ok := f.admitted[id]; ok {
+		if time.Since(t) < f.options.cooldown {
+			return true
+		}
+		delete(f.admitted, id)
+	}
+	f.ejected[id] = true
+	return false
+}
+
+func (f *failFilter[T]) ExhaustReason() (string, string) {
+	return "fail", "all objects are marked as failed"
+}
+
 type healthCheckFilter[T any] struct {
 	maxFails int
 }
@@ -99,6 +215,10 @@ func (f *healthCheckFilter[T]) Filter(ctx context.Context, vs ...T) []T {
 	return l
 }
 
+func (f *healthCheckFilter[T]) Name() string {
+	return "healthCheck"
+}
+
 type backupFilter[T any] struct{}
 
 // BackupFilter filters the backup objects.
@@ -115,11 +235,9 @@ func (f *backupFilter[T]) Filter(ctx context.Context, vs ...T) []T {
 
 	var l, backups []T
 	for _, v := range vs {
-		if mi, _ := any(v).(metadata.Metadatable); mi != nil {
-			if mdutil.GetBool(mi.Metadata(), labelBackup) {
-				backups = append(backups, v)
-				continue
-			}
+		if isBackup(v) {
+			backups = append(backups, v)
+			continue
 		}
 		l = append(l, v)
 	}
@@ -129,3 +247,430 @@ func (f *backupFilter[T]) Filter(ctx context.Context, vs ...T) []T {
 	}
 	return l
 }
+
+func (f *backupFilter[T]) Name() string {
+	return "backup"
+}
+
+func isBackup(v any) bool {
+	if mi, _ := v.(metadata.Metadatable); mi != nil {
+		return mdutil.GetBool(mi.Metadata(), labelBackup)
+	}
+	return false
+}
+
+type priorityFilter[T any] struct{}
+
+// PriorityFilter filters the objects by their priority tiers, only the objects of the lowest-numbered tier are returned,
+// so a lower tier is used only when all the objects of the higher tiers are filtered out by the preceding filters.
+// The tier is the tier label of the object, the objects without the label are of tier 0, or tier 1 if they are backups,
+// so it generalizes BackupFilter. Note that the tier label is unrelated to the priority label of the health checker,
+// whose scale is the opposite: a lower tier is preferred for selection, while a higher priority is checked more frequently.
+func PriorityFilter[T any]() selector.Filter[T] {
+	return &priorityFilter[T]{}
+}
+
+// Filter filters the objects of the lowest tier.
+func (f *priorityFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if len(vs) <= 1 {
+		return vs
+	}
+
+	var l []T
+	minTier := 0
+	for i, v := range vs {
+		tier := tierOf(v)
+		if i == 0 || tier < minTier {
+			minTier = tier
+			l = l[:0]
+		}
+		if tier == minTier {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func (f *priorityFilter[T]) Name() string {
+	return "priority"
+}
+
+func tierOf(v any) int {
+	if mi, _ := v.(metadata.Metadatable); mi != nil && mi.Metadata() != nil && mi.Metadata().IsExists(labelTier) {
+		return mdutil.GetInt(mi.Metadata(), labelTier)
+	}
+	if isBackup(v) {
+		return 1
+	}
+	return 0
+}
+
+type defaultFilter[T any] struct{}
+
+// DefaultFilter filters the default objects, which have the default label set to true.
+// The default objects are the last resort, they are returned only if all the other objects,
+// including the backups, are filtered out by the preceding filters.
+// It should precede BackupFilter, so the precedence is primary → backup → default.
+func DefaultFilter[T any]() selector.Filter[T] {
+	return &defaultFilter[T]{}
+}
+
+// Filter filters default objects.
+func (f *defaultFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if len(vs) <= 1 {
+		return vs
+	}
+
+	var l, defaults []T
+	for _, v := range vs {
+		if isDefault(v) {
+			defaults = append(defaults, v)
+			continue
+		}
+		l = append(l, v)
+	}
+
+	if len(l) == 0 {
+		return defaults
+	}
+	return l
+}
+
+func (f *defaultFilter[T]) Name() string {
+	return "default"
+}
+
+func isDefault(v any) bool {
+	if mi, _ := v.(metadata.Metadatable); mi != nil {
+		return mdutil.GetBool(mi.Metadata(), labelDefault)
+	}
+	return false
+}
+
+// MaxActiveSetter is implemented by CapFilter to adjust the cap at runtime.
+type MaxActiveSetter interface {
+	SetMaxActive(n int)
+}
+
+type capFilter[T any] struct {
+	maxActive atomic.Int64
+}
+
+// CapFilter caps the number of objects exposed to the strategy.
+// The objects are ordered by their identities, and the first maxActive of them are kept,
+// all the objects are kept if maxActive <= 0 or it exceeds the number of objects.
+// The cap can be adjusted at runtime by the MaxActiveSetter interface.
+func CapFilter[T any](maxActive int) selector.Filter[T] {
+	f := &capFilter[T]{}
+	f.SetMaxActive(maxActive)
+	return f
+}
+
+func (f *capFilter[T]) Name() string {
+	return "cap"
+}
+
+func (f *capFilter[T]) SetMaxActive(n int) {
+	f.maxActive.Store(int64(n))
+}
+
+// Filter filters the objects beyond the cap.
+func (f *capFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	n := int(f.maxActive.Load())
+	if n <= 0 || len(vs) <= n {
+		return vs
+	}
+
+	l := make([]T, len(vs))
+	copy(l, vs)
+	sort.SliceStable(l, func(i, j int) bool {
+		return identity(l[i]) < identity(l[j])
+	})
+	return l[:n]
+}
+
+type maxConnsFilter[T any] struct {
+	defaultMax int
+}
+
+// MaxConnsFilter filters the saturated objects whose active connections (see Connectable) exceed
+// their maxConns metadata label, falling back to defaultMax, an object has no limit if the limit is <= 0.
+// If all the objects are saturated, all of them are returned so that the traffic still flows.
+func MaxConnsFilter[T any](defaultMax int) selector.Filter[T] {
+	return &maxConnsFilter[T]{
+		defaultMax: defaultMax,
+	}
+}
+
+// Filter filters the saturated objects.
+func (f *maxConnsFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if len(vs) <= 1 {
+		return vs
+	}
+
+	var l []T
+	for _, v := range vs {
+		if !f.saturated(v) {
+			l = append(l, v)
+		}
+	}
+	if len(l) == 0 {
+		return vs
+	}
+	return l
+}
+
+func (f *maxConnsFilter[T]) saturated(v any) bool {
+	c, ok := v.(Connectable)
+	if !ok {
+		return false
+	}
+
+	maxConns := f.defaultMax
+	if mi, _ := v.(metadata.Metadatable); mi != nil && mi.Metadata() != nil && mi.Metadata().IsExists(labelMaxConns) {
+		maxConns = mdutil.GetInt(mi.Metadata(), labelMaxConns)
+	}
+	return maxConns > 0 && c.ActiveConns() > int64(maxConns)
+}
+
+func (f *maxConnsFilter[T]) Name() string {
+	return "maxConns"
+}
+
+type metadataMatchFilter[T any] struct {
+	key          string
+	valueFromCtx func(context.Context) string
+}
+
+// MetadataMatchFilter keeps the objects whose metadata value of key equals the value derived from the context by valueFromCtx,
+// for example the zone of the client for the locality-aware routing.
+// All the objects are returned if the context value is empty or no object matches.
+func MetadataMatchFilter[T any](key string, valueFromCtx func(context.Context) string) selector.Filter[T] {
+	return &metadataMatchFilter[T]{
+		key:          key,
+		valueFromCtx: valueFromCtx,
+	}
+}
+
+// Filter filters the objects not matching the context value.
+func (f *metadataMatchFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if len(vs) <= 1 || f.key == "" || f.valueFromCtx == nil {
+		return vs
+	}
+	value := f.valueFromCtx(ctx)
+	if value == "" {
+		return vs
+	}
+
+	var l []T
+	for _, v := range vs {
+		if mi, _ := any(v).(metadata.Metadatable); mi != nil && mdutil.GetString(mi.Metadata(), f.key) == value {
+			l = append(l, v)
+		}
+	}
+	if len(l) == 0 {
+		return vs
+	}
+	return l
+}
+
+func (f *metadataMatchFilter[T]) Name() string {
+	return "metadataMatch"
+}
+
+type latencySLAFilter[T any] struct {
+	maxLatency time.Duration
+	strict     bool
+}
+
+// LatencySLAFilter filters the objects whose latency exceeds maxLatency,
+// the objects without latency data are treated as passing.
+// If no object meets the SLA, all the objects are returned,
+// or none of them if strict is true.
+func LatencySLAFilter[T any](maxLatency time.Duration, strict bool) selector.Filter[T] {
+	return &latencySLAFilter[T]{
+		maxLatency: maxLatency,
+		strict:     strict,
+	}
+}
+
+// Filter filters slow objects.
+func (f *latencySLAFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if f.maxLatency <= 0 || len(vs) == 0 {
+		return vs
+	}
+
+	var l []T
+	for _, v := range vs {
+		if ls, ok := any(v).(LatencyStater); ok {
+			if latency := ls.Latency(); latency > f.maxLatency {
+				continue
+			}
+		}
+		l = append(l, v)
+	}
+	if len(l) == 0 && !f.strict {
+		return vs
+	}
+	return l
+}
+
+func (f *latencySLAFilter[T]) ExhaustReason() (string, string) {
+	return "latencySLA", "all objects exceed the latency of " + f.maxLatency.String()
+}
+
+type visitedFilter[T any] struct {
+	strict bool
+}
+
+// VisitedFilter filters the objects which are already traversed by the request to prevent the routing loops,
+// the visited set is the xctx.Visited in the context, which is set once per route by Chain.Route if absent.
+// The selector created by NewSelector adds the identity of the selected object to the visited set if present,
+// so each hop appends its choice for the later hops.
+//
+// If all the objects are visited, they are all kept unless strict is true.
+func VisitedFilter[T any](strict bool) selector.Filter[T] {
+	return &visitedFilter[T]{
+		strict: strict,
+	}
+}
+
+// Filter filters the visited objects.
+func (f *visitedFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	visited := xctx.VisitedFromContext(ctx)
+	if visited == nil || len(vs) == 0 {
+		return vs
+	}
+
+	var l []T
+	for _, v := range vs {
+		if !visited.Contains(identity(v)) {
+			l = append(l, v)
+		}
+	}
+	if len(l) == 0 && !f.strict {
+		return vs
+	}
+	return l
+}
+
+func (f *visitedFilter[T]) ExhaustReason() (string, string) {
+	return "visited", "all objects are visited"
+}
+
+type allowListFilter[T any] struct{}
+
+// AllowListFilter restricts the objects to the allow list in the context (see xctx.ContextWithAllowList),
+// the objects are matched by their identities, e.g. the node name, or the address if the node has no name.
+// It is a hard constraint, nothing is available if no object is allowed.
+// All the objects are kept if no allow list is present in the context.
+func AllowListFilter[T any]() selector.Filter[T] {
+	return &allowListFilter[T]{}
+}
+
+// Filter filters the objects not in the allow list.
+func (f *allowListFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	allowList := xctx.AllowListFromContext(ctx)
+	if allowList == nil {
+		return vs
+	}
+
+	var l []T
+	for _, v := range vs {
+		if _, ok := allowList[identity(v)]; ok {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func (f *allowListFilter[T]) ExhaustReason() (string, string) {
+	return "allowList", "no object is allowed"
+}
+
+type maintenanceFilter[T any] struct{}
+
+// MaintenanceFilter filters the objects in maintenance, which have the maintenance label set to true,
+// the health checker does not probe them as well, so they receive neither traffic nor probes during the planned work.
+func MaintenanceFilter[T any]() selector.Filter[T] {
+	return &maintenanceFilter[T]{}
+}
+
+// Filter filters the objects in maintenance.
+func (f *maintenanceFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	var l []T
+	for _, v := range vs {
+		if !inMaintenance(v) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func (f *maintenanceFilter[T]) ExhaustReason() (string, string) {
+	return "maintenance", "all objects are in maintenance"
+}
+
+func inMaintenance(v any) bool {
+	if mi, _ := v.(metadata.Metadatable); mi != nil {
+		return mdutil.GetBool(mi.Metadata(), labelMaintenance)
+	}
+	return false
+}
+
+// Drainer is implemented by the filters and the selectors which can drain the objects at runtime.
+type Drainer[T any] interface {
+	// SetDrain drains the object v from the selection or undrains it.
+	SetDrain(v T, drain bool)
+}
+
+type drainFilter[T any] struct {
+	drained sync.Map
+}
+
+// DrainFilter filters the drained objects, which have the drain label set to true or are drained by SetDrain of the filter.
+// Unlike the backup objects, the drained objects are never re-admitted, even if all the other objects are filtered.
+// Unlike the objects in maintenance, the drained objects are still probed by the health checker,
+// so their counters and health state survive and they are ready to serve once undrained.
+//
+// The filter implements Drainer, the objects are tracked by their identities,
+// so v is drained in the selector of the filter until it is undrained.
+func DrainFilter[T any]() selector.Filter[T] {
+	return &drainFilter[T]{}
+}
+
+// SetDrain implements Drainer interface.
+func (f *drainFilter[T]) SetDrain(v T, drain bool) {
+	if drain {
+		f.drained.Store(identity(v), struct{}{})
+	} else {
+		f.drained.Delete(identity(v))
+	}
+}
+
+// Filter filters the drained objects.
+func (f *drainFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	var l []T
+	for _, v := range vs {
+		if !f.isDrained(v) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func (f *drainFilter[T]) ExhaustReason() (string, string) {
+	return "drain", "all objects are drained"
+}
+
+func (f *drainFilter[T]) Name() string {
+	return "drain"
+}
+
+func (f *drainFilter[T]) isDrained(v T) bool {
+	if mi, _ := any(v).(metadata.Metadatable); mi != nil && mdutil.GetBool(mi.Metadata(), labelDrain) {
+		return true
+	}
+	_, ok := f.drained.Load(identity(v))
+	return ok
+}
@@ -0,0 +1,188 @@
+package selector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+func TestDefaultFilter(t *testing.T) {
+	primary := newTestNode("primary", map[string]any{})
+	backup := newTestNode("backup", map[string]any{labelBackup: true})
+	def := newTestNode("default", map[string]any{labelDefault: true})
+	nodes := []*testNode{def, backup, primary}
+
+	sel := NewSelector(
+		FIFOStrategy[*testNode](),
+		FailFilter[*testNode](1, DefaultFailTimeout),
+		DefaultFilter[*testNode](),
+		BackupFilter[*testNode](),
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		failed   []*testNode
+		expected *testNode
+	}{
+		{"primary", nil, primary},
+		{"backup", []*testNode{primary}, backup},
+		{"default", []*testNode{primary, backup}, def},
+		{"none", []*testNode{primary, backup, def}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markFailed(nodes, tt.failed...)
+
+			if got := sel.Select(ctx, nodes...); got != tt.expected {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+
+	// the default objects are kept by the filter alone if no other object is left.
+	if got := DefaultFilter[*testNode]().Filter(ctx, def); len(got) != 1 || got[0] != def {
+		t.Errorf("got %v, expected the default object", got)
+	}
+}
+
+func TestDrainFilter(t *testing.T) {
+	a := newTestNode("a", map[string]any{})
+	b := newTestNode("b", map[string]any{labelDrain: true})
+	c := newTestNode("c", map[string]any{})
+	nodes := []*testNode{a, b, c}
+
+	drain := DrainFilter[*testNode]()
+	sel := NewSelector(
+		RoundRobinStrategy[*testNode](),
+		FailFilter[*testNode](1, DefaultFailTimeout),
+		drain,
+		BackupFilter[*testNode](),
+	)
+	ctx := context.Background()
+
+	assertNotSelected := func(drained ...*testNode) {
+		t.Helper()
+		for i := 0; i < 10; i++ {
+			got := sel.Select(ctx, nodes...)
+			for _, v := range drained {
+				if got == v {
+					t.Fatalf("got drained object %s", v.id)
+				}
+			}
+		}
+	}
+
+	assertNotSelected(b)
+
+	sel.(Drainer[*testNode]).SetDrain(a, true)
+	assertNotSelected(a, b)
+	if got := sel.Select(ctx, nodes...); got != c {
+		t.Errorf("got %v, expected the undrained object c", got)
+	}
+
+	// the drained objects are not re-admitted even if all the others are down.
+	markFailed(nodes, c)
+	if got := sel.Select(ctx, nodes...); got != nil {
+		t.Errorf("got %s, expected no object", got.id)
+	}
+	markFailed(nodes)
+
+	// the drain state belongs to the filter.
+	if got := DrainFilter[*testNode]().Filter(ctx, nodes...); len(got) != 2 || got[0] != a || got[1] != c {
+		t.Errorf("got %d objects, expected the undrained objects a and c", len(got))
+	}
+
+	sel.(Drainer[*testNode]).SetDrain(a, false)
+	if got := drain.Filter(ctx, nodes...); len(got) != 2 || got[0] != a || got[1] != c {
+		t.Errorf("got %d objects, expected the undrained objects a and c", len(got))
+	}
+}
+
+func TestFailFilterHardFailWeight(t *testing.T) {
+	a := newTestNode("a", map[string]any{})
+	b := newTestNode("b", map[string]any{})
+	nodes := []*testNode{a, b}
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		weight int
+		err    error
+		alive  bool
+	}{
+		{"soft", 3, context.DeadlineExceeded, true},
+		{"hard", 3, fmt.Errorf("dial: %w", syscall.ECONNREFUSED), false},
+		{"unweighted", 0, syscall.ECONNREFUSED, true},
+		{"success", 3, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markFailed(nodes)
+			sel := NewSelector(
+				FIFOStrategy[*testNode](),
+				FailFilter[*testNode](3, DefaultFailTimeout, FailFilterHardFailWeightOption(tt.weight)),
+			)
+			sel.(ErrorReporter[*testNode]).ReportError(a, tt.err, 0)
+
+			if got := sel.Select(ctx, nodes...) == a; got != tt.alive {
+				t.Errorf("got alive %v, expected %v (fails %d)", got, tt.alive, a.marker.Count())
+			}
+		})
+	}
+
+	if got := ClassifyError(errors.New("unknown")); got != OutcomeFailure {
+		t.Errorf("got outcome %d, expected OutcomeFailure", got)
+	}
+}
+
+func TestPriorityFilter(t *testing.T) {
+	a := newTestNode("a", map[string]any{})
+	b := newTestNode("b", map[string]any{labelTier: 0})
+	c := newTestNode("c", map[string]any{labelBackup: true})
+	d := newTestNode("d", map[string]any{labelTier: 1})
+	e := newTestNode("e", map[string]any{labelTier: 2})
+	nodes := []*testNode{e, d, c, b, a}
+
+	sel := NewSelector(
+		RoundRobinStrategy[*testNode](),
+		FailFilter[*testNode](1, DefaultFailTimeout),
+		PriorityFilter[*testNode](),
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		failed   []*testNode
+		expected []*testNode
+	}{
+		{"tier0", nil, []*testNode{b, a}},
+		{"tier1", []*testNode{a, b}, []*testNode{d, c}},
+		{"tier2", []*testNode{a, b, c, d}, []*testNode{e}},
+		{"partial", []*testNode{a, c}, []*testNode{b}},
+		{"none", []*testNode{a, b, c, d, e}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markFailed(nodes, tt.failed...)
+
+			// the strategy rotates over all the objects of the surviving tier.
+			counts := make(map[*testNode]int)
+			for i := 0; i < 2*len(nodes); i++ {
+				if v := sel.Select(ctx, nodes...); v != nil {
+					counts[v]++
+				}
+			}
+			if len(counts) != len(tt.expected) {
+				t.Fatalf("got %d selected objects, expected %d", len(counts), len(tt.expected))
+			}
+			for _, v := range tt.expected {
+				if counts[v] == 0 {
+					t.Errorf("object %s is not selected", v.id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package selector
+
+import (
+	"context"
+
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/selector"
+	mdx "github.com/go-gost/x/metadata"
+	mdutil "github.com/go-gost/x/metadata/util"
+)
+
+// NodeGroup is a group of the objects which have the same value of the group label,
+// it is the object selected by the outer strategy of GroupStrategy.
+type NodeGroup[T any] struct {
+	Name   string
+	Nodes  []T
+	weight int
+}
+
+// ID implements Identifiable interface.
+func (g *NodeGroup[T]) ID() string {
+	return g.Name
+}
+
+// Metadata implements metadata.Metadatable interface,
+// the weight of the group is the sum of the weights of its members.
+func (g *NodeGroup[T]) Metadata() metadata.Metadata {
+	return mdx.NewMetadata(map[string]any{
+		labelWeight: g.weight,
+	})
+}
+
+type groupStrategy[T any] struct {
+	label  string
+	outer  selector.Strategy[*NodeGroup[T]]
+	groups map[string]selector.Strategy[T]
+	def    selector.Strategy[T]
+}
+
+// GroupStrategy is a strategy combinator for the heterogeneous nodes.
+// The nodes are partitioned by the value of the metadata label (defaults to "group"),
+// the nodes without the label belong to the group with empty name.
+// The outer strategy picks a group, for example RandomStrategy picks the groups by their weights,
+// then the strategy of the group in groups, or def if absent, selects a node in the group.
+func GroupStrategy[T any](label string, outer selector.Strategy[*NodeGroup[T]], groups map[string]selector.Strategy[T], def selector.Strategy[T]) selector.Strategy[T] {
+	if label == "" {
+		label = labelGroup
+	}
+	if outer == nil {
+		outer = RandomStrategy[*NodeGroup[T]]()
+	}
+	if def == nil {
+		def = RoundRobinStrategy[T]()
+	}
+	return &groupStrategy[T]{
+		label:  label,
+		outer:  outer,
+		groups: groups,
+		def:    def,
+	}
+}
+
+func (s *groupStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	groups := partition(vs, s.label)
+	g := groups[0]
+	if len(groups) > 1 {
+		if g = s.outer.Apply(ctx, groups...); g == nil {
+			return
+		}
+	}
+
+	if st := s.groups[g.Name]; st != nil {
+		return st.Apply(ctx, g.Nodes...)
+	}
+	return s.def.Apply(ctx, g.Nodes...)
+}
+
+// partition partitions vs by the value of the label in order of their first appearance.
+func partition[T any](vs []T, label string) []*NodeGroup[T] {
+	var groups []*NodeGroup[T]
+	index := make(map[string]*NodeGroup[T])
+	for _, v := range vs {
+		var name string
+		if mi, _ := any(v).(metadata.Metadatable); mi != nil {
+			name = mdutil.GetString(mi.Metadata(), label)
+		}
+		g := index[name]
+		if g == nil {
+			g = &NodeGroup[T]{Name: name}
+			index[name] = g
+			groups = append(groups, g)
+		}
+		g.Nodes = append(g.Nodes, v)
+		g.weight += weightOf(v)
+	}
+	return groups
+}
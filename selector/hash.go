@@ -0,0 +1,128 @@
+package selector
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	xctx "github.com/go-gost/x/ctx"
+	ictx "github.com/go-gost/x/internal/ctx"
+)
+
+// HashKeyFunc extracts the hash key from context, ok is false if the key is not available.
+type HashKeyFunc func(ctx context.Context) (key string, ok bool)
+
+// ParseHashKey parses the source of the hash key:
+//   - "" or "hash": the hash source in context.
+//   - "clientIP": the IP address of the client.
+//   - "clientIP/<v4>[,<v6>]": the subnet of the client IP address, for example "clientIP/24,64",
+//     the IPv6 address is not masked if <v6> is omitted, see ClientIPHashKey.
+//   - "header:<name>": the value of the HTTP request header <name>.
+//   - "sni": the TLS server name.
+//   - "destination": the destination hash key in context.
+func ParseHashKey(source string) HashKeyFunc {
+	switch {
+	case strings.EqualFold(source, "destination"):
+		return destinationHashKey
+	case strings.EqualFold(source, "clientIP"):
+		return clientIPHashKey
+	case len(source) > len("clientIP/") && strings.EqualFold(source[:len("clientIP/")], "clientIP/"):
+		v4, v6, _ := strings.Cut(source[len("clientIP/"):], ",")
+		v4Bits, _ := strconv.Atoi(strings.TrimSpace(v4))
+		v6Bits, _ := strconv.Atoi(strings.TrimSpace(v6))
+		return ClientIPHashKey(v4Bits, v6Bits)
+	case strings.EqualFold(source, "sni"):
+		return sniHashKey
+	case len(source) > len("header:") && strings.EqualFold(source[:len("header:")], "header:"):
+		return headerHashKey(source[len("header:"):])
+	default:
+		return sourceHashKey
+	}
+}
+
+func sourceHashKey(ctx context.Context) (string, bool) {
+	if h := xctx.HashFromContext(ctx); h != nil {
+		return h.Source, true
+	}
+	return "", false
+}
+
+func destinationHashKey(ctx context.Context) (string, bool) {
+	if h := xctx.HashFromContext(ctx); h != nil && h.Destination != "" {
+		return h.Destination, true
+	}
+	return "", false
+}
+
+func clientIPHashKey(ctx context.Context) (string, bool) {
+	return clientIPKey(ctx, 0, 0)
+}
+
+// ClientIPHashKey extracts the client IP address from context as the hash key,
+// so the clients have the source IP affinity without populating the hash source in context.
+// The address is normalized, the IPv4-mapped IPv6 address is treated as IPv4 and the zone is removed,
+// then masked to the prefix of v4Bits for IPv4 and v6Bits for IPv6 (for example /24 and /64),
+// so the clients in the same subnet are hashed to the same key. A prefix out of range disables the masking.
+//
+// The client address is the source address in context (see xctx.SrcAddrFromContext),
+// falling back to the client IP of the recorder object.
+func ClientIPHashKey(v4Bits, v6Bits int) HashKeyFunc {
+	return func(ctx context.Context) (string, bool) {
+		return clientIPKey(ctx, v4Bits, v6Bits)
+	}
+}
+
+func clientIPKey(ctx context.Context, v4Bits, v6Bits int) (string, bool) {
+	var host string
+	if addr := xctx.SrcAddrFromContext(ctx); addr != nil {
+		if h, _, err := net.SplitHostPort(addr.String()); err == nil {
+			host = h
+		}
+	}
+	if host == "" {
+		if ro := ictx.RecorderObjectFromContext(ctx); ro != nil {
+			host = ro.ClientIP
+		}
+	}
+	if host == "" {
+		return "", false
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		// not an IP address, such as a unix socket path.
+		return host, true
+	}
+	ip = ip.Unmap().WithZone("")
+
+	bits := v6Bits
+	if ip.Is4() {
+		bits = v4Bits
+	}
+	if bits > 0 && bits < ip.BitLen() {
+		if prefix, err := ip.Prefix(bits); err == nil {
+			return prefix.String(), true
+		}
+	}
+	return ip.String(), true
+}
+
+func sniHashKey(ctx context.Context) (string, bool) {
+	if ro := ictx.RecorderObjectFromContext(ctx); ro != nil && ro.TLS != nil && ro.TLS.ServerName != "" {
+		return ro.TLS.ServerName, true
+	}
+	return "", false
+}
+
+func headerHashKey(name string) HashKeyFunc {
+	return func(ctx context.Context) (string, bool) {
+		if ro := ictx.RecorderObjectFromContext(ctx); ro != nil && ro.HTTP != nil {
+			if v := ro.HTTP.Request.Header.Get(name); v != "" {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
@@ -1,38 +1,154 @@
 package selector
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net"
 	"net/http"
+	"os"
+	"os/exec"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/metadata"
 	"github.com/go-gost/core/selector"
+	mdutil "github.com/go-gost/x/metadata/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
+// the labels of the per-node overrides of the health check config.
+const (
+	labelHealthInterval = "health.interval"
+	labelHealthTimeout  = "health.timeout"
+	labelHealthPath     = "health.path"
+	labelHealthType     = "health.type"
+)
+
+// maxExpectBodySize is the maximum number of bytes of the response body read by the HTTP check.
+const maxExpectBodySize = 64 * 1024
+
 type CheckType string
 
 const (
 	CheckTypeTCP  CheckType = "tcp"
 	CheckTypeHTTP CheckType = "http"
+	CheckTypeTLS  CheckType = "tls"
+	CheckTypeGRPC CheckType = "grpc"
+	CheckTypeUDP  CheckType = "udp"
+	CheckTypeExec CheckType = "exec"
+	CheckTypeFunc CheckType = "func"
 )
 
+// PathMode is the aggregation mode of the results of the HTTP check paths.
+type PathMode string
+
+const (
+	// PathModeAll passes if all the paths pass.
+	PathModeAll PathMode = "all"
+	// PathModeAny passes if any of the paths passes.
+	PathModeAny PathMode = "any"
+)
+
+// HealthCheckFunc is the custom check of the node address, a nil error means healthy.
+type HealthCheckFunc func(ctx context.Context, addr string) error
+
+// healthCheckAddrEnv is the environment variable of the node address passed to the exec check.
+const healthCheckAddrEnv = "GOST_HEALTH_CHECK_ADDR"
+
 type HealthCheckConfig struct {
-	Interval     time.Duration
-	Timeout      time.Duration
-	Type         CheckType
-	Path         string
-	ExpectStatus int
+	Interval               time.Duration
+	Timeout                time.Duration
+	Type                   CheckType
+	Path                   string
+	ExpectStatus           int
+	ExpectBody             string
+	Method                 string
+	Header                 http.Header
+	FollowRedirects        bool
+	Paths                  []string
+	PathMode               PathMode
+	MinTLSVersion          uint16
+	CertExpiry             time.Duration
+	CertName               string
+	IdleTimeout            time.Duration
+	DependencyPath         string
+	DependencyExpectStatus int
+	DependencyDegradeOnly  bool
+	ReuseRetry             bool
+	Retries                int
+	RetryBackoff           time.Duration
+	PriorityInterval       bool
+	GRPCService            string
+	GRPCSecure             bool
+	GRPCInsecureSkipVerify bool
+	HTTPS                  bool
+	TLSConfig              *tls.Config
+	BackoffMaxInterval     time.Duration
+	BackoffFactor          float64
+	Jitter                 float64
+	Concurrency            int
+	UDPPayload             []byte
+	UDPExpectPrefix        []byte
+	ExecCommand            []string
+	Func                   HealthCheckFunc
+}
+
+type healthState struct {
+	passes    int
+	fails     int
+	degraded  bool
+	unhealthy bool
+	lastCheck time.Time
+	lastError string
+	latency   time.Duration
 }
 
 type HealthChecker struct {
 	config     HealthCheckConfig
 	logger     logger.Logger
 	cancelFunc context.CancelFunc
+	// done is closed when the check loop started by Start exits.
+	done       chan struct{}
+	runMu      sync.Mutex
+	states     map[string]*healthState
+	status     map[string]*NodeHealth
+	mu         sync.RWMutex
+	lastActive atomic.Int64
+	wakeup     chan struct{}
+	paused     atomic.Bool
+	breaker    *CircuitBreaker
+	// breakerInterval is the check interval of the nodes with open breakers.
+	breakerInterval time.Duration
+	// trip is notified when a breaker trips open.
+	trip chan struct{}
+	// checked is the last check time of the probes, it is only accessed by the check loop.
+	checked map[string]time.Time
+	// offsets is the jitter of the next check time of the probes in fractions of the interval,
+	// it is only accessed by the check loop.
+	offsets map[string]float64
+	// onChange is called on the health transitions of the nodes.
+	onChange []func(node any, healthy bool)
+	// onProbe is called on every probe of an address.
+	onProbe []func(addr string, latency time.Duration, err error)
+	// sem bounds the concurrent probes of the checker, it is nil if unlimited.
+	sem chan struct{}
+	// dialer dials the TCP based checks, the checks dial directly if it is nil.
+	dialer func(ctx context.Context, addr string) (net.Conn, error)
+	// client is the client of the HTTP checks.
+	client *http.Client
 }
 
 type HealthCheckerOption func(*HealthChecker)
@@ -61,12 +177,259 @@ func HealthCheckPathOption(path string) HealthCheckerOption {
 	}
 }
 
+// HealthCheckPathsOption sets the paths of the HTTP check, they take precedence over the path of HealthCheckPathOption,
+// the paths are requested in order within the timeout, and the results are aggregated by mode (defaults to PathModeAll).
+func HealthCheckPathsOption(paths []string, mode PathMode) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.Paths = paths
+		hc.config.PathMode = mode
+	}
+}
+
 func HealthCheckExpectStatusOption(status int) HealthCheckerOption {
 	return func(hc *HealthChecker) {
 		hc.config.ExpectStatus = status
 	}
 }
 
+// HealthCheckExpectBodyOption makes the HTTP check fail if the response body does not contain the substring,
+// at most the first maxExpectBodySize bytes of the body are read.
+func HealthCheckExpectBodyOption(body string) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.ExpectBody = body
+	}
+}
+
+// HealthCheckMinTLSVersionOption sets the lowest TLS version accepted by the TLS and HTTPS checks,
+// the check fails if the negotiated version is below v.
+func HealthCheckMinTLSVersionOption(v uint16) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.MinTLSVersion = v
+	}
+}
+
+// HealthCheckCertExpiryOption makes the TLS and HTTPS checks fail if the certificate of the node
+// is not valid yet, or expires in less than d, so the expiring certificates are flagged before they break.
+// It is disabled by default (d = 0).
+func HealthCheckCertExpiryOption(d time.Duration) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.CertExpiry = d
+	}
+}
+
+// HealthCheckCertNameOption makes the TLS and HTTPS checks fail if the certificate of the node
+// is not issued for name, either as the common name or as a subject alternative name.
+// It is disabled by default (name is empty).
+func HealthCheckCertNameOption(name string) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.CertName = name
+	}
+}
+
+// HealthCheckIdleTimeoutOption enables the lazy mode,
+// the health checks are paused if the checker is not touched (see Touch) for the duration d,
+// and resumed on the next touch.
+func HealthCheckIdleTimeoutOption(d time.Duration) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.IdleTimeout = d
+	}
+}
+
+// HealthCheckDependencyOption sets the dependency check for the HTTP check,
+// the node is healthy only if the endpoint at path also passes with expectStatus (any 2xx/3xx if 0).
+// If degradeOnly is true the node failing the dependency check is kept up and reported as degraded,
+// otherwise it is marked down.
+func HealthCheckDependencyOption(path string, expectStatus int, degradeOnly bool) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.DependencyPath = path
+		hc.config.DependencyExpectStatus = expectStatus
+		hc.config.DependencyDegradeOnly = degradeOnly
+	}
+}
+
+// HealthCheckReuseRetryOption enables retrying the HTTP check once on a fresh connection
+// if the connection is closed or reset by the peer before the response is received (see isReuseError),
+// so that a connection dropped by an intermediary does not mark a healthy node as failed.
+// The HTTP checks never reuse the connections (see newHTTPClient), so it is a plain single retry
+// of the transient connection errors, made immediately within the timeout of the probe,
+// unlike the retries of HealthCheckRetriesOption which retry any failure after the backoff.
+// Only the GET and HEAD checks are retried.
+func HealthCheckReuseRetryOption(b bool) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.ReuseRetry = b
+	}
+}
+
+// HealthCheckRetriesOption sets the number of retries of a failed probe within a check pass,
+// the retries are made after the backoff, and the check fails only if all of them fail.
+// A pass counts once towards the failure and recovery thresholds regardless of the retries.
+// The attempts are bounded by the check timeout of the node (and by its check interval if shorter),
+// so the retries never extend a check beyond its timeout,
+// they are skipped or shortened when the timeout runs out. n = 0 disables the retries.
+func HealthCheckRetriesOption(n int, backoff time.Duration) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.Retries = n
+		hc.config.RetryBackoff = backoff
+	}
+}
+
+// HealthCheckPriorityIntervalOption derives the check interval of each node from its priority label,
+// the interval is Interval / 2^priority clamped to [Interval/4, Interval*4],
+// so the higher priority nodes are checked more frequently, and the lower priority ones less frequently.
+// The nodes without the label have priority 0, or -1 if they are backups.
+// The priority label only affects the check interval, the selection order is set by the tier label of PriorityFilter,
+// where a lower tier is preferred.
+// The nodes sharing an address are checked at the shortest interval of them.
+func HealthCheckPriorityIntervalOption(b bool) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.PriorityInterval = b
+	}
+}
+
+// HealthCheckBackoffOption enables the backoff of the failing nodes,
+// the check interval of a node is multiplied by factor (defaults to 2) for each consecutive failed check,
+// capped at maxInterval (defaults to 10 times the interval), and restored once the node passes a check.
+func HealthCheckBackoffOption(maxInterval time.Duration, factor float64) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		if factor <= 1 {
+			factor = 2
+		}
+		hc.config.BackoffMaxInterval = maxInterval
+		hc.config.BackoffFactor = factor
+	}
+}
+
+// HealthCheckJitterOption randomizes the check time of each node by up to ±fraction of its interval,
+// and delays the first checks by up to fraction of the interval,
+// so that the nodes and the checkers are not probed in synchronized bursts.
+// The fraction is clamped to [0, 1], 0 disables the jitter.
+func HealthCheckJitterOption(fraction float64) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.Jitter = min(max(fraction, 0), 1)
+	}
+}
+
+// HealthCheckConcurrencyOption limits the concurrent probes of the checker to n,
+// the check pass waits for a free slot so every due node is still probed, n <= 0 means unlimited.
+// The probes of all the checkers are also bounded by the shared pool (see SetHealthCheckPoolSize).
+func HealthCheckConcurrencyOption(n int) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.Concurrency = n
+	}
+}
+
+// HealthCheckOnChangeOption registers fn to be called when a node transitions between healthy and unhealthy,
+// it is not called on the probes which do not change the state. The nodes are healthy before the first check.
+// fn is called outside the locks of the checker but inline in the check pass,
+// so it should be fast or spawn its own goroutine.
+func HealthCheckOnChangeOption(fn func(node any, healthy bool)) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		if fn != nil {
+			hc.onChange = append(hc.onChange, fn)
+		}
+	}
+}
+
+// HealthCheckDialerOption sets the dialer of the TCP, TLS, HTTP and gRPC checks,
+// so that the nodes can be checked through a chain or a proxy. The checks dial directly by default.
+func HealthCheckDialerOption(dialer func(ctx context.Context, addr string) (net.Conn, error)) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.dialer = dialer
+	}
+}
+
+// HealthCheckCircuitBreakerOption coordinates the checker with the circuit breaker,
+// the nodes whose breakers are open are probed at the interval (defaults to 1s) from the moment they trip,
+// the passing probes close the breakers (see CircuitBreaker.Probe), then the normal interval is restored.
+func HealthCheckCircuitBreakerOption(cb *CircuitBreaker, interval time.Duration) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.breaker = cb
+		hc.breakerInterval = interval
+	}
+}
+
+// HealthCheckGRPCOption sets the gRPC check, the service is checked by the standard grpc.health.v1.Health/Check RPC,
+// an empty service checks the overall health of the server.
+// If secure is true the connection uses TLS, and the certificate of the server is verified unless insecureSkipVerify is true.
+func HealthCheckGRPCOption(service string, secure bool, insecureSkipVerify bool) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.GRPCService = service
+		hc.config.GRPCSecure = secure
+		hc.config.GRPCInsecureSkipVerify = insecureSkipVerify
+	}
+}
+
+// HealthCheckUDPOption sets the UDP check, the payload is sent to the node and any response received within the timeout passes,
+// if expectPrefix is not empty the response must start with it.
+//
+// UDP has no connection semantics, so a lost request or response, a silent server and a dead one are indistinguishable,
+// the payload should be a request the server always answers, and the failure threshold should tolerate occasional losses.
+// An ICMP port unreachable reported by the system fails the check immediately.
+func HealthCheckUDPOption(payload []byte, expectPrefix []byte) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.UDPPayload = payload
+		hc.config.UDPExpectPrefix = expectPrefix
+	}
+}
+
+// HealthCheckExecOption sets the exec check, the command is run with the node address as the last argument
+// and in the GOST_HEALTH_CHECK_ADDR environment variable, exit code 0 means healthy.
+// The command is killed after the timeout, and its stderr is written to the debug log.
+func HealthCheckExecOption(command ...string) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.ExecCommand = command
+	}
+}
+
+// HealthCheckFuncOption sets the check type to CheckTypeFunc and fn as the check,
+// the context passed to fn is canceled after the timeout.
+func HealthCheckFuncOption(fn HealthCheckFunc) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.Type = CheckTypeFunc
+		hc.config.Func = fn
+	}
+}
+
+// HealthCheckMethodOption sets the request method of the HTTP check, defaults to GET.
+// The closed connection is retried (see HealthCheckReuseRetryOption) only for the GET and HEAD methods.
+func HealthCheckMethodOption(method string) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.Method = strings.ToUpper(method)
+	}
+}
+
+// HealthCheckHeaderOption sets the request headers of the HTTP check,
+// the Host header sets the host of the request, so the virtual-hosted backends can be checked by address.
+func HealthCheckHeaderOption(header http.Header) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.Header = header
+	}
+}
+
+// HealthCheckFollowRedirectsOption makes the HTTP check follow the redirects, the status of the final response is checked.
+// The redirects are not followed by default, so the status of the redirect response itself is checked,
+// and a redirect fails the check unless its status is the expected one.
+func HealthCheckFollowRedirectsOption(b bool) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.FollowRedirects = b
+	}
+}
+
+// HealthCheckHTTPSOption makes the HTTP check use the https:// scheme.
+func HealthCheckHTTPSOption(b bool) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.HTTPS = b
+	}
+}
+
+// HealthCheckTLSConfigOption sets the TLS config of the HTTPS, TLS and secure gRPC checks,
+// it takes precedence over the default config which skips the certificate verification.
+func HealthCheckTLSConfigOption(cfg *tls.Config) HealthCheckerOption {
+	return func(hc *HealthChecker) {
+		hc.config.TLSConfig = cfg
+	}
+}
+
 func HealthCheckLoggerOption(l logger.Logger) HealthCheckerOption {
 	return func(hc *HealthChecker) {
 		hc.logger = l
@@ -81,6 +444,12 @@ func NewHealthChecker(opts ...HealthCheckerOption) *HealthChecker {
 			Type:         CheckTypeTCP,
 			ExpectStatus: 200,
 		},
+		states:  make(map[string]*healthState),
+		status:  make(map[string]*NodeHealth),
+		wakeup:  make(chan struct{}, 1),
+		trip:    make(chan struct{}, 1),
+		checked: make(map[string]time.Time),
+		offsets: make(map[string]float64),
 	}
 	for _, opt := range opts {
 		opt(hc)
@@ -91,58 +460,441 @@ func NewHealthChecker(opts ...HealthCheckerOption) *HealthChecker {
 	if hc.config.Timeout <= 0 {
 		hc.config.Timeout = 5 * time.Second
 	}
+	if hc.config.Concurrency > 0 {
+		hc.sem = make(chan struct{}, hc.config.Concurrency)
+	}
+	hc.client = hc.newHTTPClient()
+	if hc.config.BackoffFactor > 0 && hc.config.BackoffMaxInterval <= 0 {
+		hc.config.BackoffMaxInterval = 10 * hc.config.Interval
+	}
+	if hc.breaker != nil {
+		if hc.breakerInterval <= 0 {
+			hc.breakerInterval = time.Second
+		}
+		hc.breaker.notifyOpen(func() {
+			select {
+			case hc.trip <- struct{}{}:
+			default:
+			}
+		})
+	}
 	return hc
 }
 
+// Start starts the check loop of the nodes, the checker can be restarted after Stop.
+// If the previous loop is still running, for example StopContext returned with an error,
+// the new loop starts after the previous one exits.
 func (hc *HealthChecker) Start(nodes []any) {
+	hc.lastActive.Store(time.Now().UnixNano())
+
+	hc.runMu.Lock()
+	defer hc.runMu.Unlock()
+
+	if hc.cancelFunc != nil {
+		hc.cancelFunc()
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	hc.cancelFunc = cancel
-	go hc.run(ctx, nodes)
+
+	prev, done := hc.done, make(chan struct{})
+	hc.done = done
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		hc.run(ctx, nodes)
+	}()
 }
 
+// Stop stops the check loop and waits for the in-flight probes to finish. It is idempotent.
 func (hc *HealthChecker) Stop() {
-	if hc.cancelFunc != nil {
-		hc.cancelFunc()
+	hc.StopContext(context.Background())
+}
+
+// StopContext stops the check loop and waits for the in-flight probes to finish until ctx is done,
+// the error of ctx is returned if the probes are still running.
+func (hc *HealthChecker) StopContext(ctx context.Context) error {
+	hc.runMu.Lock()
+	cancel, done := hc.cancelFunc, hc.done
+	hc.cancelFunc = nil
+	hc.runMu.Unlock()
+
+	if cancel != nil {
+		cancel()
 	}
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		hc.client.CloseIdleConnections()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running reports whether the check loop is started and not stopped.
+func (hc *HealthChecker) Running() bool {
+	hc.runMu.Lock()
+	defer hc.runMu.Unlock()
+
+	return hc.cancelFunc != nil
+}
+
+// Touch notifies the checker that the nodes are in use,
+// it resumes the paused health checks in lazy mode.
+// The selector calls it on each selection with SelectorActivityOption.
+func (hc *HealthChecker) Touch() {
+	idle := hc.idle()
+	hc.lastActive.Store(time.Now().UnixNano())
+	if idle {
+		select {
+		case hc.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pause halts the health checks, for example during a maintenance window of the backends,
+// the state of the nodes and their markers are preserved. It is idempotent.
+func (hc *HealthChecker) Pause() {
+	hc.paused.Store(true)
+}
+
+// Resume resumes the paused health checks from the current state, the nodes are checked immediately.
+// It is idempotent.
+func (hc *HealthChecker) Resume() {
+	if hc.paused.CompareAndSwap(true, false) {
+		select {
+		case hc.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (hc *HealthChecker) idle() bool {
+	return hc.config.IdleTimeout > 0 &&
+		time.Since(time.Unix(0, hc.lastActive.Load())) > hc.config.IdleTimeout
 }
 
 func (hc *HealthChecker) run(ctx context.Context, nodes []any) {
-	ticker := time.NewTicker(hc.config.Interval)
+	tick := hc.config.Interval
+	if hc.config.PriorityInterval {
+		tick = hc.config.Interval / maxIntervalScale
+	}
+	if hc.breaker != nil {
+		tick = min(tick, hc.breakerInterval)
+	}
+	for _, v := range nodes {
+		d := hc.intervalOf(v)
+		if hc.config.PriorityInterval {
+			d /= maxIntervalScale
+		}
+		tick = min(tick, d)
+	}
+	if hc.config.Jitter > 0 {
+		tick = min(tick, max(time.Duration(float64(tick)*hc.config.Jitter), minJitterTick))
+
+		delay := time.Duration(rand.Float64() * hc.config.Jitter * float64(hc.config.Interval))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
-	hc.checkAll(nodes)
+	if !hc.paused.Load() {
+		hc.checkAll(ctx, nodes, 0)
+	}
 
 	for {
 		select {
 		case <-ticker.C:
-			hc.checkAll(nodes)
+			if hc.idle() || hc.paused.Load() {
+				continue
+			}
+			hc.checkAll(ctx, nodes, tick)
+		case <-hc.wakeup:
+			if hc.paused.Load() {
+				continue
+			}
+			hc.checkAll(ctx, nodes, 0)
+		case <-hc.trip:
+			if hc.paused.Load() {
+				continue
+			}
+			hc.checkAll(ctx, nodes, tick)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (hc *HealthChecker) checkAll(nodes []any) {
+// checkAll checks the nodes concurrently in the shared pool (see SetHealthCheckPoolSize),
+// the nodes sharing an address and the probe (see probeSpec) are probed once and the result is applied to all of them,
+// and the nodes with the maintenance label are skipped.
+// With tick > 0 only the probes due within half a tick are checked, otherwise all of them.
+// It returns after the submitted probes finish, no more probes or retries are made once ctx is done.
+func (hc *HealthChecker) checkAll(ctx context.Context, nodes []any, tick time.Duration) {
+	var specs []probeSpec
+	groups := make(map[probeSpec][]any)
+	for _, v := range nodes {
+		node, ok := v.(*chain.Node)
+		if !ok || node == nil || node.Addr == "" {
+			continue
+		}
+		// the nodes in maintenance are not probed, their markers are left untouched.
+		if inMaintenance(v) {
+			continue
+		}
+		spec := hc.probeSpec(node)
+		if _, ok := groups[spec]; !ok {
+			specs = append(specs, spec)
+		}
+		groups[spec] = append(groups[spec], v)
+	}
+
+	now := time.Now()
 	var wg sync.WaitGroup
-	for _, node := range nodes {
+	for _, spec := range specs {
+		if ctx.Err() != nil {
+			break
+		}
+		vs := groups[spec]
+		interval := hc.nodeInterval(vs[0])
+		for _, v := range vs[1:] {
+			interval = min(interval, hc.nodeInterval(v))
+		}
+		key := spec.key()
+		interval += time.Duration(float64(interval) * hc.offsets[key])
+		if tick > 0 && hc.checked[key].Add(interval).Sub(now) > tick/2 {
+			continue
+		}
+		hc.checked[key] = now
+		if hc.config.Jitter > 0 {
+			hc.offsets[key] = (2*rand.Float64() - 1) * hc.config.Jitter
+		}
+
+		if hc.sem != nil {
+			select {
+			case hc.sem <- struct{}{}:
+			case <-ctx.Done():
+				continue
+			}
+		}
+
 		wg.Add(1)
-		go func(n any) {
+		healthPool.submit(func() {
 			defer wg.Done()
-			hc.check(n)
-		}(node)
+			if hc.sem != nil {
+				defer func() { <-hc.sem }()
+			}
+			start, degraded, err := hc.probeRetry(ctx, spec, now.Add(interval))
+			// the failure of an interrupted probe is not conclusive.
+			if err != nil && ctx.Err() != nil {
+				return
+			}
+			latency := time.Since(start)
+			hc.record(spec.addr, start, latency, err, degraded)
+			for _, v := range vs {
+				hc.apply(v, start, latency, err, degraded)
+			}
+		})
 	}
 	wg.Wait()
 }
 
-func (hc *HealthChecker) check(v any) {
+// probeRetry probes by the spec and retries the failed probe (see HealthCheckRetriesOption),
+// the attempts share the timeout of the spec, and the retries are bounded by the deadline which is
+// the end of the timeout or the next check pass (next) if earlier:
+// no retry starts after it, and a retry times out at it at the latest.
+// It returns the start time and the result of the last attempt.
+func (hc *HealthChecker) probeRetry(ctx context.Context, spec probeSpec, next time.Time) (start time.Time, degraded bool, err error) {
+	start = time.Now()
+	deadline := start.Add(spec.timeout)
+	if next.Before(deadline) {
+		deadline = next
+	}
+	degraded, err = hc.probe(spec)
+	for i := 0; err != nil && i < hc.config.Retries; i++ {
+		if time.Until(deadline) <= hc.config.RetryBackoff {
+			break
+		}
+		if hc.config.RetryBackoff > 0 {
+			timer := time.NewTimer(hc.config.RetryBackoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		retry := spec
+		retry.timeout = min(spec.timeout, time.Until(deadline))
+		start = time.Now()
+		degraded, err = hc.probe(retry)
+	}
+	return
+}
+
+// minJitterTick is the minimum tick of the check loop in jitter mode.
+const minJitterTick = 100 * time.Millisecond
+
+// maxIntervalScale is the maximum scale of the check interval of a node by its priority.
+const maxIntervalScale = 4
+
+// nodeInterval returns the check interval of the node by its breaker, interval label, priority and backoff.
+func (hc *HealthChecker) nodeInterval(v any) time.Duration {
+	if hc.breaker != nil && hc.breaker.Open(v) {
+		return hc.breakerInterval
+	}
+	if !hc.config.PriorityInterval {
+		return hc.backoff(v, hc.intervalOf(v))
+	}
+
+	p := 0
+	if mi, _ := v.(metadata.Metadatable); mi != nil && mi.Metadata() != nil && mi.Metadata().IsExists(labelPriority) {
+		p = mdutil.GetInt(mi.Metadata(), labelPriority)
+	} else if isBackup(v) {
+		p = -1
+	}
+
+	interval := hc.intervalOf(v)
+	switch {
+	case p >= 2:
+		interval /= maxIntervalScale
+	case p == 1:
+		interval /= 2
+	case p == -1:
+		interval *= 2
+	case p <= -2:
+		interval *= maxIntervalScale
+	}
+	return hc.backoff(v, interval)
+}
+
+// backoff scales the interval by the consecutive failures of the node in backoff mode.
+func (hc *HealthChecker) backoff(v any, interval time.Duration) time.Duration {
+	if hc.config.BackoffFactor <= 0 || interval >= hc.config.BackoffMaxInterval {
+		return interval
+	}
+
+	hc.mu.RLock()
+	fails := 0
+	if st := hc.states[identity(v)]; st != nil {
+		fails = st.fails
+	}
+	hc.mu.RUnlock()
+
+	for ; fails > 0 && interval < hc.config.BackoffMaxInterval; fails-- {
+		interval = time.Duration(float64(interval) * hc.config.BackoffFactor)
+	}
+	return min(interval, hc.config.BackoffMaxInterval)
+}
+
+// intervalOf returns the base check interval of the node, it is overridden by the health.interval label.
+func (hc *HealthChecker) intervalOf(v any) time.Duration {
+	if mi, _ := v.(metadata.Metadatable); mi != nil && mi.Metadata() != nil {
+		if d := mdutil.GetDuration(mi.Metadata(), labelHealthInterval); d > 0 {
+			return d
+		}
+	}
+	return hc.config.Interval
+}
+
+// probeSpec is the probe of an address,
+// the type, path and timeout are overridden per node by the health.type, health.path and health.timeout labels,
+// the path is empty if it is not overridden (see paths).
+type probeSpec struct {
+	addr    string
+	typ     CheckType
+	path    string
+	timeout time.Duration
+}
+
+func (spec probeSpec) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s", spec.addr, spec.typ, spec.path, spec.timeout)
+}
+
+func (hc *HealthChecker) probeSpec(node *chain.Node) probeSpec {
+	spec := probeSpec{
+		addr:    node.Addr,
+		typ:     hc.config.Type,
+		timeout: hc.config.Timeout,
+	}
+	md := node.Metadata()
+	if md == nil {
+		return spec
+	}
+	if t := mdutil.GetString(md, labelHealthType); t != "" {
+		spec.typ = CheckType(strings.ToLower(t))
+	}
+	if path := mdutil.GetString(md, labelHealthPath); path != "" {
+		spec.path = path
+	}
+	if d := mdutil.GetDuration(md, labelHealthTimeout); d > 0 {
+		spec.timeout = d
+	}
+	return spec
+}
+
+// probe checks the address by the check type,
+// degraded is true if the address passes with a failed dependency in degrade-only mode.
+func (hc *HealthChecker) probe(spec probeSpec) (degraded bool, err error) {
+	addr, timeout := spec.addr, spec.timeout
+	switch spec.typ {
+	case CheckTypeHTTP:
+		err = hc.checkPaths(addr, hc.paths(spec), timeout)
+		if err == nil && hc.config.DependencyPath != "" {
+			if derr := hc.checkHTTP(addr, hc.config.DependencyPath, hc.config.DependencyExpectStatus, "", timeout); derr != nil {
+				derr = fmt.Errorf("dependency %s: %w", hc.config.DependencyPath, derr)
+				if hc.config.DependencyDegradeOnly {
+					degraded = true
+					if hc.logger != nil {
+						hc.logger.Debugf("health check degraded for %s: %v", addr, derr)
+					}
+				} else {
+					err = derr
+				}
+			}
+		}
+	case CheckTypeTLS:
+		err = hc.checkTLS(addr, timeout)
+	case CheckTypeGRPC:
+		err = hc.checkGRPC(addr, timeout)
+	case CheckTypeUDP:
+		err = hc.checkUDP(addr, timeout)
+	case CheckTypeExec:
+		err = hc.checkExec(addr, timeout)
+	case CheckTypeFunc:
+		err = hc.checkFunc(addr, timeout)
+	default:
+		err = hc.checkTCP(addr, timeout)
+	}
+	return
+}
+
+// apply updates the state and marker of the node by the result of the probe,
+// the latency of a passing probe is set to the node if it implements LatencySetter.
+func (hc *HealthChecker) apply(v any, start time.Time, latency time.Duration, err error, degraded bool) {
 	node, ok := v.(*chain.Node)
 	if !ok || node == nil {
 		return
 	}
-
 	addr := node.Addr
-	if addr == "" {
-		return
+
+	if ls, ok := v.(LatencySetter); ok && err == nil {
+		ls.SetLatency(latency)
 	}
 
 	marker := node.Marker()
@@ -157,12 +909,16 @@ func (hc *HealthChecker) check(v any) {
 		}
 	}
 
-	var err error
-	switch hc.config.Type {
-	case CheckTypeHTTP:
-		err = hc.checkHTTP(addr)
-	default:
-		err = hc.checkTCP(addr)
+	if hc.updateState(node, start, latency, err, degraded) {
+		hc.mu.RLock()
+		hooks := hc.onChange
+		hc.mu.RUnlock()
+		for _, fn := range hooks {
+			fn(v, err == nil)
+		}
+	}
+	if hc.breaker != nil {
+		hc.breaker.Probe(v, err == nil)
 	}
 
 	if err != nil {
@@ -178,8 +934,156 @@ func (hc *HealthChecker) check(v any) {
 	}
 }
 
-func (hc *HealthChecker) checkTCP(addr string) error {
-	conn, err := net.DialTimeout("tcp", addr, hc.config.Timeout)
+// updateState updates the state of the node by the result of a check, it reports whether the health of the node changes.
+func (hc *HealthChecker) updateState(node *chain.Node, start time.Time, latency time.Duration, err error, degraded bool) bool {
+	id := identity(node)
+	ok := err == nil
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	st := hc.states[id]
+	if st == nil {
+		st = &healthState{}
+		hc.states[id] = st
+	}
+	if ok {
+		st.passes++
+		st.fails = 0
+	} else {
+		st.passes = 0
+		st.fails++
+	}
+	st.degraded = degraded
+	st.lastCheck, st.latency = start, latency
+	st.lastError = ""
+	if err != nil {
+		st.lastError = err.Error()
+	}
+
+	changed := st.unhealthy == ok
+	st.unhealthy = !ok
+	return changed
+}
+
+// record updates the status of the address by the result of the last probe of a check pass.
+func (hc *HealthChecker) record(addr string, start time.Time, latency time.Duration, err error, degraded bool) {
+	hc.mu.Lock()
+	h := hc.status[addr]
+	if h == nil {
+		h = &NodeHealth{}
+		hc.status[addr] = h
+	}
+	h.Healthy = err == nil
+	h.Degraded = degraded
+	h.LastCheck = start
+	h.Latency = latency
+	if err != nil {
+		h.LastError = err.Error()
+		h.Passes = 0
+		h.Failures++
+	} else {
+		h.LastError = ""
+		h.Passes++
+		h.Failures = 0
+	}
+	hooks := hc.onProbe
+	hc.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(addr, latency, err)
+	}
+}
+
+// OnChange registers fn to be called when a node transitions between healthy and unhealthy,
+// like HealthCheckOnChangeOption but for a running checker.
+func (hc *HealthChecker) OnChange(fn func(node any, healthy bool)) {
+	if fn == nil {
+		return
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	// the hooks are copied on write, so the check pass can iterate them without the lock.
+	hc.onChange = append(hc.onChange[:len(hc.onChange):len(hc.onChange)], fn)
+}
+
+// OnProbe registers fn to be called on every probe of an address with its latency and result,
+// it is called outside the locks of the checker but inline in the check pass, so it should be fast.
+func (hc *HealthChecker) OnProbe(fn func(addr string, latency time.Duration, err error)) {
+	if fn == nil {
+		return
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.onProbe = append(hc.onProbe[:len(hc.onProbe):len(hc.onProbe)], fn)
+}
+
+// Status returns a snapshot of the health of the checked addresses keyed by address,
+// Fails is not set as the markers are owned by the nodes (see NodeSelectorBundle.HealthSummary).
+// With the per-node overrides (see probeSpec), the latest probe of an address wins.
+func (hc *HealthChecker) Status() map[string]NodeHealth {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	m := make(map[string]NodeHealth, len(hc.status))
+	for addr, h := range hc.status {
+		m[addr] = *h
+	}
+	return m
+}
+
+// NodeStatus returns the health of the node v by its last check, ok is false if v is not checked yet,
+// Fails is not set as the markers are owned by the nodes. Unlike Status, the nodes of the same address are told apart.
+func (hc *HealthChecker) NodeStatus(v any) (h NodeHealth, ok bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	st := hc.states[identity(v)]
+	if st == nil {
+		return
+	}
+	return NodeHealth{
+		Healthy:   !st.unhealthy,
+		Passes:    st.passes,
+		Degraded:  st.degraded,
+		LastCheck: st.lastCheck,
+		LastError: st.lastError,
+		Failures:  st.fails,
+		Latency:   st.latency,
+	}, true
+}
+
+// Degraded reports whether the node v passes the health check but fails the dependency check.
+func (hc *HealthChecker) Degraded(v any) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	if st := hc.states[identity(v)]; st != nil {
+		return st.degraded
+	}
+	return false
+}
+
+// ConsecutivePasses implements PassCounter interface.
+func (hc *HealthChecker) ConsecutivePasses(v any) (int, bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	if st := hc.states[identity(v)]; st != nil {
+		return st.passes, true
+	}
+	return 0, false
+}
+
+func (hc *HealthChecker) checkTCP(addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := hc.dial(ctx, addr)
 	if err != nil {
 		return err
 	}
@@ -187,33 +1091,338 @@ func (hc *HealthChecker) checkTCP(addr string) error {
 	return nil
 }
 
-func (hc *HealthChecker) checkHTTP(addr string) error {
-	client := &http.Client{
-		Timeout: hc.config.Timeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+func (hc *HealthChecker) checkTLS(addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rawConn, err := hc.dial(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer rawConn.Close()
+
+	cfg := hc.tlsConfig(true)
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	conn := tls.Client(rawConn, cfg)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+
+	return hc.verifyTLS(conn.ConnectionState())
+}
+
+// verifyTLS verifies the negotiated TLS connection of the TLS and HTTPS checks
+// by the minimum version, the certificate expiry and the certificate name of the config.
+func (hc *HealthChecker) verifyTLS(state tls.ConnectionState) error {
+	if v := state.Version; v < hc.config.MinTLSVersion {
+		return fmt.Errorf("tls version %s is below %s", tls.VersionName(v), tls.VersionName(hc.config.MinTLSVersion))
+	}
+	if hc.config.CertExpiry <= 0 && hc.config.CertName == "" {
+		return nil
 	}
 
-	path := hc.config.Path
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("tls: no peer certificate")
+	}
+	cert := state.PeerCertificates[0]
+	if hc.config.CertExpiry > 0 {
+		now := time.Now()
+		if now.Before(cert.NotBefore) {
+			return fmt.Errorf("tls certificate is not valid before %s", cert.NotBefore.Format(time.RFC3339))
+		}
+		if cert.NotAfter.Sub(now) < hc.config.CertExpiry {
+			return fmt.Errorf("tls certificate expires at %s", cert.NotAfter.Format(time.RFC3339))
+		}
+	}
+	if name := hc.config.CertName; name != "" &&
+		cert.Subject.CommonName != name && cert.VerifyHostname(name) != nil {
+		return fmt.Errorf("tls certificate is not issued for %s", name)
+	}
+	return nil
+}
+
+// checkUDP passes if a response to the payload is received before the deadline.
+func (hc *HealthChecker) checkUDP(addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(hc.config.UDPPayload); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64*1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(buf[:n], hc.config.UDPExpectPrefix) {
+		return fmt.Errorf("unexpected response: %q", buf[:min(n, 64)])
+	}
+	return nil
+}
+
+func (hc *HealthChecker) checkExec(addr string, timeout time.Duration) error {
+	if len(hc.config.ExecCommand) == 0 {
+		return errors.New("no command to execute")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	name, args := hc.config.ExecCommand[0], hc.config.ExecCommand[1:]
+	cmd := exec.CommandContext(ctx, name, append(args[:len(args):len(args)], addr)...)
+	cmd.Env = append(os.Environ(), healthCheckAddrEnv+"="+addr)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if stderr.Len() > 0 && hc.logger != nil {
+		hc.logger.Debugf("health check for %s: %s", addr, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	return err
+}
+
+func (hc *HealthChecker) checkFunc(addr string, timeout time.Duration) error {
+	if hc.config.Func == nil {
+		return errors.New("no check function")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return hc.config.Func(ctx, addr)
+}
+
+// checkGRPC passes only if the server reports the service as SERVING.
+func (hc *HealthChecker) checkGRPC(addr string, timeout time.Duration) error {
+	creds := insecure.NewCredentials()
+	if hc.config.GRPCSecure {
+		creds = credentials.NewTLS(hc.tlsConfig(hc.config.GRPCInsecureSkipVerify))
+	}
+	grpcOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+	}
+	if hc.dialer != nil {
+		grpcOpts = append(grpcOpts, grpc.WithContextDialer(hc.dialer))
+	}
+	conn, err := grpc.NewClient(addr, grpcOpts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: hc.config.GRPCService,
+	})
+	if err != nil {
+		return err
+	}
+	if st := resp.GetStatus(); st != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("unexpected serving status: %s", st)
+	}
+	return nil
+}
+
+// paths returns the paths of the HTTP check of the probe, the path label overrides the configured paths.
+func (hc *HealthChecker) paths(spec probeSpec) []string {
+	if spec.path != "" {
+		return []string{spec.path}
+	}
+	if len(hc.config.Paths) > 0 {
+		return hc.config.Paths
+	}
+	return []string{hc.config.Path}
+}
+
+// checkPaths checks the paths in order within the shared timeout and aggregates the results by the path mode.
+func (hc *HealthChecker) checkPaths(addr string, paths []string, timeout time.Duration) error {
+	if len(paths) == 1 {
+		return hc.checkHTTP(addr, paths[0], hc.config.ExpectStatus, hc.config.ExpectBody, timeout)
+	}
+
+	anyMode := hc.config.PathMode == PathModeAny
+	deadline := time.Now().Add(timeout)
+	var errs []error
+	for _, path := range paths {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			errs = append(errs, fmt.Errorf("%s: %w", path, context.DeadlineExceeded))
+			break
+		}
+
+		err := hc.checkHTTP(addr, path, hc.config.ExpectStatus, hc.config.ExpectBody, remaining)
+		if hc.logger != nil {
+			if err != nil {
+				hc.logger.Debugf("health check path %s failed for %s: %v", path, addr, err)
+			} else {
+				hc.logger.Debugf("health check path %s passed for %s", path, addr)
+			}
+		}
+		if err == nil && anyMode {
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			if !anyMode {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (hc *HealthChecker) checkHTTP(addr string, path string, expectStatus int, expectBody string, timeout time.Duration) error {
 	if path == "" {
 		path = "/"
 	}
+	scheme := "http"
+	if hc.config.HTTPS {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s%s", scheme, addr, path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	url := fmt.Sprintf("http://%s%s", addr, path)
-	resp, err := client.Get(url)
+	req, err := hc.newRequest(ctx, url)
 	if err != nil {
 		return err
 	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil && hc.config.ReuseRetry && isReuseError(err) && isIdempotent(req.Method) {
+		if hc.logger != nil {
+			hc.logger.Debugf("health check for %s failed on closed connection, retrying: %v", addr, err)
+		}
+		resp, err = hc.client.Do(req)
+	}
+	if err != nil {
+		return err
+	}
+	// the keep-alives are disabled, the connection is closed along with the body.
 	defer resp.Body.Close()
 
-	if hc.config.ExpectStatus > 0 && resp.StatusCode != hc.config.ExpectStatus {
+	if resp.TLS != nil {
+		if err := hc.verifyTLS(*resp.TLS); err != nil {
+			return err
+		}
+	}
+
+	if expectStatus > 0 && resp.StatusCode != expectStatus {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-		return nil
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("health check failed with status: %d", resp.StatusCode)
+	}
+
+	if expectBody != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxExpectBodySize))
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		if !bytes.Contains(body, []byte(expectBody)) {
+			return fmt.Errorf("body does not contain %q in the first %d bytes", expectBody, len(body))
+		}
+	}
+
+	return nil
+}
+
+// newRequest creates the request of the HTTP check by the method and headers of the config.
+func (hc *HealthChecker) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	method := hc.config.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
 	}
+	if len(hc.config.Header) > 0 {
+		req.Header = hc.config.Header.Clone()
+		if host := req.Header.Get("Host"); host != "" {
+			req.Host = host
+			req.Header.Del("Host")
+		}
+	}
+	return req, nil
+}
 
-	return fmt.Errorf("health check failed with status: %d", resp.StatusCode)
+// newHTTPClient creates the client shared by the HTTP checks of the checker.
+// The keep-alives are disabled, so each check tests a fresh connection to the node,
+// and no idle connection is left between the check passes.
+func (hc *HealthChecker) newHTTPClient() *http.Client {
+	tr := &http.Transport{
+		TLSClientConfig:   hc.tlsConfig(true),
+		DisableKeepAlives: true,
+	}
+	if hc.dialer != nil {
+		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return hc.dialer(ctx, addr)
+		}
+	}
+	client := &http.Client{
+		Transport: tr,
+	}
+	if !hc.config.FollowRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client
+}
+
+// dial dials the address for the TCP based checks by the dialer.
+func (hc *HealthChecker) dial(ctx context.Context, addr string) (net.Conn, error) {
+	if hc.dialer != nil {
+		return hc.dialer(ctx, addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// tlsConfig returns a copy of the TLS config set by HealthCheckTLSConfigOption,
+// or the default config with the certificate verification skipped if insecureSkipVerify is true.
+func (hc *HealthChecker) tlsConfig(insecureSkipVerify bool) *tls.Config {
+	if hc.config.TLSConfig != nil {
+		return hc.config.TLSConfig.Clone()
+	}
+	return &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+}
+
+// isReuseError reports whether err is caused by a connection closed by the peer,
+// that is the connection is closed (EOF) or reset before the response is received.
+// It is safe to retry the check request if its method is idempotent.
+func isReuseError(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// isIdempotent reports whether the check request of the method can be retried.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,173 @@
+package selector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/chain"
+)
+
+func TestHealthCheckConcurrency(t *testing.T) {
+	const limit = 3
+
+	var active, peak, calls atomic.Int64
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		calls.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil, errors.New("refused")
+	}
+	hc := NewHealthChecker(
+		HealthCheckConcurrencyOption(limit),
+		HealthCheckDialerOption(dialer),
+	)
+
+	var nodes []any
+	for i := 0; i < 20; i++ {
+		nodes = append(nodes, chain.NewNode(fmt.Sprintf("node-%d", i), fmt.Sprintf("127.0.0.1:%d", 10000+i)))
+	}
+	hc.checkAll(context.Background(), nodes, 0)
+
+	if got := calls.Load(); got != int64(len(nodes)) {
+		t.Errorf("got %d probes, expected %d", got, len(nodes))
+	}
+	if got := peak.Load(); got > limit {
+		t.Errorf("got %d concurrent probes, expected at most %d", got, limit)
+	}
+	if got := peak.Load(); got < 2 {
+		t.Errorf("got %d concurrent probes, expected the probes to run concurrently", got)
+	}
+}
+
+func TestHealthCheckRetriesTimeout(t *testing.T) {
+	var calls atomic.Int64
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		calls.Add(1)
+		return nil, errors.New("refused")
+	}
+	hc := NewHealthChecker(
+		HealthCheckTimeoutOption(100*time.Millisecond),
+		HealthCheckRetriesOption(100, 20*time.Millisecond),
+		HealthCheckDialerOption(dialer),
+	)
+
+	node := chain.NewNode("node", "127.0.0.1:10000")
+	start := time.Now()
+	if _, _, err := hc.probeRetry(context.Background(), hc.probeSpec(node), start.Add(time.Hour)); err == nil {
+		t.Fatal("got no error probing a refused node")
+	}
+
+	// the retries stop at the timeout rather than the check interval.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("got the retries taking %v, expected them bounded by the timeout", elapsed)
+	}
+	if got := calls.Load(); got < 2 || got > 6 {
+		t.Errorf("got %d probes, expected the retries within the timeout", got)
+	}
+}
+
+func TestHealthCheckReuseRetry(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request is dropped before the response.
+		if calls.Add(1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	for _, tt := range []struct {
+		name  string
+		retry bool
+		calls int64
+	}{
+		{"disabled", false, 1},
+		{"enabled", true, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			hc := NewHealthChecker(HealthCheckTypeOption(CheckTypeHTTP), HealthCheckReuseRetryOption(tt.retry))
+			err := hc.checkHTTP(addr, "/", http.StatusOK, "", time.Second)
+			if got := err == nil; got != tt.retry {
+				t.Errorf("got passed %v, expected %v: %v", got, tt.retry, err)
+			}
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("got %d requests, expected %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestHealthCheckTLSVerify(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+
+	addr := strings.TrimPrefix(srv.URL, "https://")
+	tests := []struct {
+		name string
+		opts []HealthCheckerOption
+		pass bool
+	}{
+		{"default", nil, true},
+		{"version", []HealthCheckerOption{HealthCheckMinTLSVersionOption(tls.VersionTLS12)}, true},
+		{"old version", []HealthCheckerOption{HealthCheckMinTLSVersionOption(tls.VersionTLS13)}, false},
+		{"expiry", []HealthCheckerOption{HealthCheckCertExpiryOption(24 * time.Hour)}, true},
+		{"expiring", []HealthCheckerOption{HealthCheckCertExpiryOption(100 * 365 * 24 * time.Hour)}, false},
+		{"name", []HealthCheckerOption{HealthCheckCertNameOption("example.com")}, true},
+		{"wrong name", []HealthCheckerOption{HealthCheckCertNameOption("other.test")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := NewHealthChecker(append(tt.opts, HealthCheckHTTPSOption(true))...)
+			if err := hc.checkTLS(addr, time.Second); (err == nil) != tt.pass {
+				t.Errorf("tls: got error %v, expected passed %v", err, tt.pass)
+			}
+			if err := hc.checkHTTP(addr, "/", 0, "", time.Second); (err == nil) != tt.pass {
+				t.Errorf("https: got error %v, expected passed %v", err, tt.pass)
+			}
+		})
+	}
+}
+
+func BenchmarkHealthCheckHTTP(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	hc := NewHealthChecker(HealthCheckTypeOption(CheckTypeHTTP))
+	defer hc.Stop()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := hc.checkHTTP(addr, "/", http.StatusOK, "", time.Second); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
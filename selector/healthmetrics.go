@@ -0,0 +1,123 @@
+package selector
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	xmetrics "github.com/go-gost/x/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type healthCollector struct {
+	hc       *HealthChecker
+	healthy  *prometheus.GaugeVec
+	duration *prometheus.HistogramVec
+	failures *prometheus.CounterVec
+}
+
+// RegisterHealthMetrics registers the health metrics of the checker with reg, labeled by the hop name and address:
+// a gauge of the health of each checked address (1 is healthy, 0 is unhealthy),
+// a histogram of the latencies of the passing probes and a counter of the failed probes.
+//
+// The gauges are seeded from HealthChecker.Status, follow the health transitions of the nodes (see HealthChecker.OnChange)
+// and are refreshed from HealthChecker.Status on each scrape, so the addresses probed only once are reported as well.
+// Nothing is reported while the checker is stopped. The metrics of the same hop registered before, for example
+// by the previous config before a reload, are replaced. It is a no-op if the checker or reg is nil.
+func RegisterHealthMetrics(reg prometheus.Registerer, hc *HealthChecker, hop string) error {
+	if reg == nil || hc == nil {
+		return nil
+	}
+
+	labels := prometheus.Labels{"hop": hop}
+	c := &healthCollector{
+		hc: hc,
+		healthy: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        string(xmetrics.MetricHealthCheckHealthyGauge),
+				Help:        "Health of the checked addresses (1 = healthy, 0 = unhealthy)",
+				ConstLabels: labels,
+			},
+			[]string{"address"}),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        string(xmetrics.MetricHealthCheckDurationObserver),
+				Help:        "Distribution of health check probe latencies",
+				ConstLabels: labels,
+				Buckets: []float64{
+					.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+				},
+			},
+			[]string{"address"}),
+		failures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        string(xmetrics.MetricHealthCheckFailuresCounter),
+				Help:        "Total failed health check probes",
+				ConstLabels: labels,
+			},
+			[]string{"address"}),
+	}
+	c.refresh()
+
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return err
+		}
+		reg.Unregister(are.ExistingCollector)
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	hc.OnChange(c.onChange)
+	hc.OnProbe(c.onProbe)
+	return nil
+}
+
+func (c *healthCollector) onChange(node any, healthy bool) {
+	if n, ok := node.(*chain.Node); ok && n != nil {
+		c.healthy.WithLabelValues(n.Addr).Set(gaugeValue(healthy))
+	}
+}
+
+func (c *healthCollector) onProbe(addr string, latency time.Duration, err error) {
+	if err != nil {
+		c.failures.WithLabelValues(addr).Inc()
+		return
+	}
+	c.duration.WithLabelValues(addr).Observe(latency.Seconds())
+}
+
+// refresh sets the gauges by the status of the checker.
+func (c *healthCollector) refresh() {
+	for addr, h := range c.hc.Status() {
+		c.healthy.WithLabelValues(addr).Set(gaugeValue(h.Healthy))
+	}
+}
+
+// Describe implements prometheus.Collector interface.
+func (c *healthCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.healthy.Describe(ch)
+	c.duration.Describe(ch)
+	c.failures.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (c *healthCollector) Collect(ch chan<- prometheus.Metric) {
+	if !c.hc.Running() {
+		return
+	}
+	c.refresh()
+
+	c.healthy.Collect(ch)
+	c.duration.Collect(ch)
+	c.failures.Collect(ch)
+}
+
+func gaugeValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
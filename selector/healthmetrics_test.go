@@ -0,0 +1,66 @@
+package selector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegisterHealthMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	// the hops sharing a backend report their own health of it.
+	a, b := NewHealthChecker(), NewHealthChecker()
+	a.record("10.0.0.1:80", time.Now(), time.Millisecond, nil, false)
+	b.record("10.0.0.1:80", time.Now(), time.Millisecond, errors.New("refused"), false)
+	for hop, hc := range map[string]*HealthChecker{"a": a, "b": b} {
+		if err := RegisterHealthMetrics(reg, hc, hop); err != nil {
+			t.Fatalf("register hop %s: %v", hop, err)
+		}
+		hc.Start(nil)
+		defer hc.Stop()
+	}
+
+	healthy := func() map[string]float64 {
+		t.Helper()
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := make(map[string]float64)
+		for _, f := range families {
+			if f.GetName() != "gost_selector_health_check_healthy" {
+				continue
+			}
+			for _, metric := range f.GetMetric() {
+				var hop, addr string
+				for _, l := range metric.GetLabel() {
+					switch l.GetName() {
+					case "hop":
+						hop = l.GetValue()
+					case "address":
+						addr = l.GetValue()
+					}
+				}
+				m[hop+"/"+addr] = metric.GetGauge().GetValue()
+			}
+		}
+		return m
+	}
+
+	// the gauges are seeded from the status before any transition.
+	if got := healthy(); len(got) != 2 || got["a/10.0.0.1:80"] != 1 || got["b/10.0.0.1:80"] != 0 {
+		t.Errorf("got gauges %v, expected a healthy and b unhealthy", got)
+	}
+
+	// the metrics of a hop are replaced by its new checker, the stopped checker reports nothing.
+	c := NewHealthChecker()
+	if err := RegisterHealthMetrics(reg, c, "b"); err != nil {
+		t.Fatalf("register hop b again: %v", err)
+	}
+	if got := healthy(); len(got) != 1 || got["a/10.0.0.1:80"] != 1 {
+		t.Errorf("got gauges %v, expected only a", got)
+	}
+}
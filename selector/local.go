@@ -0,0 +1,101 @@
+package selector
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/selector"
+	mdutil "github.com/go-gost/x/metadata/util"
+)
+
+type preferLocalStrategy[T any] struct {
+	delegate selector.Strategy[T]
+}
+
+// PreferLocalStrategy is a strategy for node selector.
+// The local nodes are preferred, and the delegate strategy selects among them if any exists,
+// otherwise among all the nodes.
+//
+// A node is local if it has the local metadata label set to true,
+// or its address is a loopback address, localhost or an address of the local network interfaces.
+func PreferLocalStrategy[T any](delegate selector.Strategy[T]) selector.Strategy[T] {
+	if delegate == nil {
+		delegate = RoundRobinStrategy[T]()
+	}
+	return &preferLocalStrategy[T]{
+		delegate: delegate,
+	}
+}
+
+func (s *preferLocalStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	var locals []T
+	for _, v := range vs {
+		if isLocal(v) {
+			locals = append(locals, v)
+		}
+	}
+	if len(locals) > 0 {
+		return s.delegate.Apply(ctx, locals...)
+	}
+	return s.delegate.Apply(ctx, vs...)
+}
+
+func isLocal(v any) bool {
+	if mi, _ := v.(metadata.Metadatable); mi != nil {
+		if md := mi.Metadata(); md != nil && md.IsExists(labelLocal) {
+			return mdutil.GetBool(md, labelLocal)
+		}
+	}
+
+	node, _ := v.(*chain.Node)
+	if node == nil {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(node.Addr)
+	if err != nil {
+		host = node.Addr
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, local := range localIPs() {
+		if local.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	localIPsOnce sync.Once
+	localIPList  []net.IP
+)
+
+// localIPs returns the addresses of the local network interfaces, they are loaded once.
+func localIPs() []net.IP {
+	localIPsOnce.Do(func() {
+		addrs, _ := net.InterfaceAddrs()
+		for _, addr := range addrs {
+			if ipn, ok := addr.(*net.IPNet); ok {
+				localIPList = append(localIPList, ipn.IP)
+			}
+		}
+	})
+	return localIPList
+}
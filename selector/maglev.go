@@ -0,0 +1,134 @@
+package selector
+
+import (
+	"context"
+	"hash/crc32"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"sync"
+
+	"github.com/go-gost/core/selector"
+)
+
+// DefaultMaglevTableSize is the default size of the Maglev lookup table.
+const DefaultMaglevTableSize = 65537
+
+// maglevTable is the Maglev lookup table, each entry is the index of the object owning it.
+type maglevTable []int
+
+// newMaglevTable populates the table of size m (a prime) by the permutations of the ids,
+// each id takes the free entries in turn by its own permutation, so the entries are spread evenly.
+func newMaglevTable(ids []string, m int) maglevTable {
+	table := make(maglevTable, m)
+	for i := range table {
+		table[i] = -1
+	}
+	if len(ids) == 0 {
+		return table
+	}
+
+	offsets := make([]uint64, len(ids))
+	skips := make([]uint64, len(ids))
+	for i, id := range ids {
+		if id == "" {
+			id = strconv.Itoa(i)
+		}
+		h := fnv.New64a()
+		h.Write([]byte(id))
+		offsets[i] = h.Sum64() % uint64(m)
+		skips[i] = uint64(crc32.ChecksumIEEE([]byte(id)))%uint64(m-1) + 1
+	}
+
+	next := make([]uint64, len(ids))
+	for filled := 0; ; {
+		for i := range ids {
+			c := (offsets[i] + next[i]*skips[i]) % uint64(m)
+			for table[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % uint64(m)
+			}
+			table[c] = i
+			next[i]++
+			if filled++; filled == m {
+				return table
+			}
+		}
+	}
+}
+
+type maglevStrategy[T any] struct {
+	options     strategyOptions
+	size        int
+	fingerprint uint64
+	table       maglevTable
+	r           *rand.Rand
+	mu          sync.Mutex
+}
+
+// MaglevStrategy is a strategy for node selector by Maglev hashing.
+// The key (see StrategyHashKeyOption, the hash source in context by default) is looked up in a table of tableSize entries
+// populated by the permutations of the node identities, so the keys are spread evenly over the nodes,
+// and a change of the nodes remaps only a small portion of the keys.
+//
+// The tableSize should be a prime much larger than the number of the nodes (at least 100 times),
+// it is rounded up to the next prime, and defaults to DefaultMaglevTableSize.
+// The table is rebuilt only when the set of the nodes changes, and the node is selected randomly if the key is not available.
+func MaglevStrategy[T any](tableSize int, opts ...StrategyOption) selector.Strategy[T] {
+	if tableSize <= 0 {
+		tableSize = DefaultMaglevTableSize
+	}
+	options := newStrategyOptions(opts)
+	return &maglevStrategy[T]{
+		options: options,
+		size:    nextPrime(tableSize),
+		r:       options.rand,
+	}
+}
+
+func (s *maglevStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.options.hashKey(ctx)
+	if !ok {
+		return vs[s.r.Intn(len(vs))]
+	}
+
+	ids := identities(vs)
+	if fingerprint := fingerprintOf(ids); s.table == nil || fingerprint != s.fingerprint {
+		s.table = newMaglevTable(ids, s.size)
+		s.fingerprint = fingerprint
+	}
+
+	if i := s.table[crc32.ChecksumIEEE([]byte(key))%uint32(len(s.table))]; i >= 0 {
+		return vs[i]
+	}
+	return vs[0]
+}
+
+// nextPrime returns the smallest prime not less than n.
+func nextPrime(n int) int {
+	if n <= 2 {
+		return 2
+	}
+	if n%2 == 0 {
+		n++
+	}
+	for ; ; n += 2 {
+		prime := true
+		for d := 3; d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package selector
+
+import (
+	"sync"
+)
+
+// DefaultHealthCheckPoolSize is the default limit of the concurrent probes of all the health checkers.
+const DefaultHealthCheckPoolSize = 256
+
+// healthPool is the worker pool shared by all the health checkers,
+// it bounds the total number of the concurrent probes regardless of the number of checkers.
+var healthPool = newWorkerPool(DefaultHealthCheckPoolSize)
+
+// SetHealthCheckPoolSize sets the limit of the concurrent probes of all the health checkers,
+// n <= 0 restores the default. The probes running or waiting keep the previous limit.
+func SetHealthCheckPoolSize(n int) {
+	healthPool.resize(n)
+}
+
+type workerPool struct {
+	sem chan struct{}
+	mu  sync.RWMutex
+}
+
+func newWorkerPool(n int) *workerPool {
+	p := &workerPool{}
+	p.resize(n)
+	return p
+}
+
+func (p *workerPool) resize(n int) {
+	if n <= 0 {
+		n = DefaultHealthCheckPoolSize
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sem = make(chan struct{}, n)
+}
+
+// submit runs the task in the pool, it blocks until a worker is available.
+func (p *workerPool) submit(task func()) {
+	p.mu.RLock()
+	sem := p.sem
+	p.mu.RUnlock()
+
+	sem <- struct{}{}
+	go func() {
+		defer func() { <-sem }()
+		task()
+	}()
+}
@@ -0,0 +1,100 @@
+package selector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+// WeightProvider provides the weights of the objects for the weighted strategies,
+// ok is false if the weight of v is unknown, then the weight from metadata is used.
+type WeightProvider interface {
+	Weight(v any) (weight int, ok bool)
+}
+
+// WeightSource loads the weights from an external source, such as a metrics system,
+// the weights are keyed by object identity.
+type WeightSource interface {
+	Weights(ctx context.Context) (map[string]int, error)
+}
+
+// CachedWeightProvider is a WeightProvider backed by a WeightSource,
+// the weights are refreshed from the source periodically and cached between refreshes,
+// so that no lookup to the source is made per selection.
+// If a refresh fails the previous weights are kept.
+type CachedWeightProvider struct {
+	source     WeightSource
+	period     time.Duration
+	weights    map[string]int
+	mu         sync.RWMutex
+	logger     logger.Logger
+	cancelFunc context.CancelFunc
+}
+
+// NewCachedWeightProvider creates a CachedWeightProvider and starts refreshing the weights from source
+// with the period (defaults to 10s) until it is closed.
+func NewCachedWeightProvider(source WeightSource, period time.Duration, log logger.Logger) *CachedWeightProvider {
+	if period <= 0 {
+		period = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &CachedWeightProvider{
+		source:     source,
+		period:     period,
+		logger:     log,
+		cancelFunc: cancel,
+	}
+	go p.run(ctx)
+
+	return p
+}
+
+func (p *CachedWeightProvider) run(ctx context.Context) {
+	ticker := time.NewTicker(p.period)
+	defer ticker.Stop()
+
+	for {
+		p.refresh(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *CachedWeightProvider) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.period)
+	defer cancel()
+
+	weights, err := p.source.Weights(ctx)
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Warnf("refresh weights: %v", err)
+		}
+		return
+	}
+
+	p.mu.Lock()
+	p.weights = weights
+	p.mu.Unlock()
+}
+
+// Weight implements WeightProvider interface.
+func (p *CachedWeightProvider) Weight(v any) (int, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	weight, ok := p.weights[identity(v)]
+	return weight, ok
+}
+
+// Close stops refreshing the weights.
+func (p *CachedWeightProvider) Close() error {
+	p.cancelFunc()
+	return nil
+}
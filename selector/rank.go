@@ -0,0 +1,482 @@
+package selector
+
+import (
+	"context"
+	"hash/crc32"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/selector"
+	xctx "github.com/go-gost/x/ctx"
+)
+
+// Ranker is implemented by the strategies which can rank the candidates by their preference,
+// so the callers can try the objects in order for failover without repeated selections.
+type Ranker[T any] interface {
+	// Rank returns the candidates in order of preference, the best first.
+	// It does not advance the state of the strategy, such as the round-robin counter.
+	Rank(ctx context.Context, vs ...T) []T
+}
+
+// Rank ranks the filtered objects by the strategy if it implements Ranker,
+// otherwise the filtered objects are returned in their order,
+// the strategy is never applied so its state is not advanced.
+func (s *defaultSelector[T]) Rank(ctx context.Context, vs ...T) []T {
+	vs, err := s.filter(ctx, vs)
+	if err != nil {
+		return nil
+	}
+	return rankOf(ctx, s.getStrategy(), vs)
+}
+
+// rankOf ranks vs by the strategy if it implements Ranker, otherwise vs is in its order.
+func rankOf[T any](ctx context.Context, strategy selector.Strategy[T], vs []T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+	if r, ok := strategy.(Ranker[T]); ok {
+		return r.Rank(ctx, vs...)
+	}
+	return rotate(vs, 0)
+}
+
+// rotate returns a copy of vs rotated to start from index start.
+func rotate[T any](vs []T, start int) []T {
+	l := make([]T, 0, len(vs))
+	l = append(l, vs[start:]...)
+	return append(l, vs[:start]...)
+}
+
+// Rank is the rotation starting from the node to be selected next.
+func (s *roundRobinStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+	n := atomic.LoadUint64(&s.counter)
+	return rotate(vs, int(n%uint64(len(vs))))
+}
+
+// Rank is the order of the nodes.
+func (s *fifoStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	return rotate(vs, 0)
+}
+
+// Rank is a weighted random order by the weights used by Apply, the nodes of higher weights tend to be ranked higher,
+// the drained nodes (of weight 0) are the last, or the order is uniformly random if all the nodes are drained.
+// The minimum shares are not taken into account.
+func (s *randomStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	weights := make([]float64, len(vs))
+	var sum float64
+	for i := range vs {
+		weight := float64(s.options.drainableWeight(vs[i])) * s.options.recoveryFactor(vs[i])
+		weights[i] = float64(int(weight * weightScale))
+		sum += weights[i]
+	}
+	if sum <= 0 {
+		return permute(vs, s.r.Perm(len(vs)))
+	}
+
+	index := make([]int, len(vs))
+	for i := range index {
+		index[i] = i
+	}
+	order := weightedOrder(s.r, index, func(i int) float64 { return weights[i] })
+	return permute(vs, order)
+}
+
+// weightedOrder returns a weighted random order of vs,
+// the objects of zero weight are the last in their order.
+func weightedOrder[T any](r *rand.Rand, vs []T, weight func(v T) float64) []T {
+	// weighted sampling without replacement by the keys u^(1/w).
+	keys := make([]float64, len(vs))
+	for i := range vs {
+		if w := weight(vs[i]); w > 0 {
+			keys[i] = math.Pow(r.Float64(), 1/w)
+		}
+	}
+	return sortBy(vs, func(i, j int) bool { return keys[i] > keys[j] })
+}
+
+// Rank is the rotation starting from the node of the hash key,
+// or a random order if the key is not available. The bounded load is not taken into account.
+func (s *hashStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+	if key, ok := s.options.hashKey(ctx); ok {
+		value := uint64(crc32.ChecksumIEEE([]byte(key)))
+		return rotate(vs, int(value%uint64(len(vs))))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return permute(vs, s.r.Perm(len(vs)))
+}
+
+// Rank is the rotation starting from the node to be selected next.
+func (s *hashRoundRobinStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	n := atomic.LoadUint64(&s.counter)
+	if h := xctx.HashFromContext(ctx); h != nil {
+		n += uint64(crc32.ChecksumIEEE([]byte(h.Source)))
+	}
+	return rotate(vs, int(n%uint64(len(vs))))
+}
+
+// Rank is the ascending order of the active connections, the ties are in order of the tie-break mode.
+func (s *leastConnStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conns := make([]int64, len(vs))
+	for i := range vs {
+		if c, ok := any(vs[i]).(Connectable); ok {
+			conns[i] = c.ActiveConns()
+		}
+	}
+	return s.tb.rank(vs, func(i int) float64 { return float64(conns[i]) })
+}
+
+// Rank is the ascending order of the latencies, the nodes without latency are the last,
+// and the ties are in order of the tie-break mode.
+func (s *leastLatencyStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latencies := make([]time.Duration, len(vs))
+	for i := range vs {
+		latencies[i] = math.MaxInt64
+		if ls, ok := any(vs[i]).(LatencyStater); ok {
+			if latency := ls.Latency(); latency > 0 {
+				latencies[i] = latency
+			}
+		}
+	}
+	return s.tb.rank(vs, func(i int) float64 { return float64(latencies[i]) })
+}
+
+// rank returns vs in ascending order of the score of each index,
+// the ties are shuffled in random mode and sorted by identity in stable mode,
+// and in round-robin mode the leading ties are rotated to the one to be selected next.
+func (t *tieBreaker[T]) rank(vs []T, score func(i int) float64) []T {
+	index := make([]int, len(vs))
+	for i := range index {
+		index[i] = i
+	}
+	switch t.mode {
+	case TieBreakStable:
+		ids := identities(vs)
+		sort.SliceStable(index, func(i, j int) bool { return ids[index[i]] < ids[index[j]] })
+	case TieBreakRoundRobin:
+	default:
+		index = t.r.Perm(len(vs))
+	}
+	sort.SliceStable(index, func(i, j int) bool { return score(index[i]) < score(index[j]) })
+
+	if t.mode == TieBreakRoundRobin {
+		n := 1
+		for n < len(index) && score(index[n]) == score(index[0]) {
+			n++
+		}
+		copy(index, rotate(index[:n], int(t.counter%uint64(n))))
+	}
+	return permute(vs, index)
+}
+
+// Rank is the order of the replicas, the nodes in the distinct zones first,
+// then the rest of the nodes clockwise on the ring.
+func (s *zoneReplicaHashStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	return s.replicasOf(ctx, vs, len(vs))
+}
+
+// Rank is the order of the distinct nodes clockwise on the ring from the key of the current window,
+// or a random order if the key is not available.
+func (s *windowHashStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.options.hashKey(ctx)
+	if !ok {
+		return permute(vs, s.r.Perm(len(vs)))
+	}
+
+	window := time.Now().UnixNano() / int64(s.window)
+	hash := crc32.ChecksumIEEE([]byte(key + "/" + strconv.FormatInt(window, 10)))
+
+	return ringOrder(s.rings.get(identities(vs)), hash, vs)
+}
+
+// Rank is the order of the distinct nodes clockwise on the ring from the key,
+// or a random order if the key is not available.
+func (s *consistentHashStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.options.hashKey(ctx)
+	if !ok {
+		return permute(vs, s.r.Perm(len(vs)))
+	}
+	return ringOrder(s.rings.get(identities(vs)), crc32.ChecksumIEEE([]byte(key)), vs)
+}
+
+// Rank is the rotation starting from the node of the current bucket.
+func (s *timeBucketStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+	n := uint64(time.Now().UnixNano() / int64(s.bucket))
+	return rotate(vs, int(n%uint64(len(vs))))
+}
+
+// Rank is a weighted random order by the weights scaled by the error rates.
+func (s *errorRateStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return weightedOrder(s.r, vs, func(v T) float64 {
+		share := 1.0
+		if st := s.stats[identity(v)]; st != nil {
+			share = max(1-st.ErrorRate, minErrorRateShare)
+		}
+		return float64(s.options.weight(v)) * share * s.options.recoveryFactor(v)
+	})
+}
+
+// Rank is the ascending order of the active and pending connections, the ties are shuffled.
+// The pending connections are not increased.
+func (s *pendingLeastConnStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vs = permute(vs, s.r.Perm(len(vs)))
+	now := time.Now()
+	conns := make([]int64, len(vs))
+	for i := range vs {
+		id := identity(vs[i])
+		var active int64
+		if st := s.stats[id]; st != nil {
+			active = st.ActiveConns
+		}
+		if c, ok := any(vs[i]).(Connectable); ok {
+			active = c.ActiveConns()
+		}
+		conns[i] = active + s.pendings(id, now)
+	}
+	return sortBy(vs, func(i, j int) bool { return conns[i] < conns[j] })
+}
+
+// Rank is the descending order of the composite scores, the ties are in order of the candidates.
+func (s *compositeMetadataStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+	scores := s.scores(vs)
+	return sortBy(vs, func(i, j int) bool { return scores[i] > scores[j] })
+}
+
+// Rank is the ascending order of the costs, the ties are shuffled.
+func (s *leastCostStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	vs = permute(vs, s.r.Perm(len(vs)))
+	s.mu.Unlock()
+
+	costs := make([]float64, len(vs))
+	for i := range vs {
+		costs[i] = s.costFn(vs[i])
+	}
+	return sortBy(vs, func(i, j int) bool { return costs[i] < costs[j] })
+}
+
+// Rank is a weighted random order by the weights with the preference of the target version.
+func (s *preferVersionStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return weightedOrder(s.r, vs, func(v T) float64 {
+		weight := float64(weightOf(v))
+		if s.target != "" && matchVersion(versionOf(v), s.target) {
+			weight *= s.preference
+		}
+		return weight
+	})
+}
+
+// Rank is the ranking of the delegate, the hinted node or the nodes in the hinted zone first.
+// The affinity hint is not updated.
+func (s *affinityStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	hint := xctx.AffinityFromContext(ctx)
+	if hint == nil || !hint.Valid {
+		return rankOf(ctx, s.delegate, vs)
+	}
+
+	switch s.mode {
+	case AffinityIndex:
+		if hint.Index >= 0 && hint.Index < len(vs) {
+			rest := make([]T, 0, len(vs)-1)
+			rest = append(rest, vs[:hint.Index]...)
+			rest = append(rest, vs[hint.Index+1:]...)
+			return append([]T{vs[hint.Index]}, rankOf(ctx, s.delegate, rest)...)
+		}
+	default:
+		if hint.Zone != "" {
+			return rankSplit(ctx, s.delegate, vs, func(v T) bool { return zoneOf(v) == hint.Zone })
+		}
+	}
+	return rankOf(ctx, s.delegate, vs)
+}
+
+// Rank is the ranking of the delegate, the local nodes first.
+func (s *preferLocalStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	return rankSplit(ctx, s.delegate, vs, func(v T) bool { return isLocal(v) })
+}
+
+// rankSplit ranks the objects matching pred and then the rest by the strategy.
+func rankSplit[T any](ctx context.Context, strategy selector.Strategy[T], vs []T, pred func(v T) bool) []T {
+	var matches, rest []T
+	for _, v := range vs {
+		if pred(v) {
+			matches = append(matches, v)
+		} else {
+			rest = append(rest, v)
+		}
+	}
+	return append(rankOf(ctx, strategy, matches), rankOf(ctx, strategy, rest)...)
+}
+
+// Rank is the order of the regions starting from the one to be selected next,
+// and the nodes of each region are ranked by the least-conn strategy.
+func (s *regionStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	groups := partition(vs, labelRegion)
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	s.mu.Lock()
+	start := 0
+	for i, group := range groups {
+		if group.Name > s.last {
+			start = i
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	l := make([]T, 0, len(vs))
+	for _, g := range rotate(groups, start) {
+		l = append(l, rankOf(ctx, s.inner, g.Nodes)...)
+	}
+	return l
+}
+
+// Rank is the order of the groups ranked by the outer strategy,
+// and the nodes of each group are ranked by the strategy of the group.
+func (s *groupStrategy[T]) Rank(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	groups := partition(vs, s.label)
+	if len(groups) > 1 {
+		groups = rankOf(ctx, s.outer, groups)
+	}
+
+	l := make([]T, 0, len(vs))
+	for _, g := range groups {
+		st := s.groups[g.Name]
+		if st == nil {
+			st = s.def
+		}
+		l = append(l, rankOf(ctx, st, g.Nodes)...)
+	}
+	return l
+}
+
+// ringOrder returns the distinct objects clockwise on the ring from the key.
+func ringOrder[T any](ring *hashRing, key uint32, vs []T) []T {
+	l := make([]T, 0, len(vs))
+	seen := make([]bool, len(vs))
+	ring.walk(key, func(i int) bool {
+		if !seen[i] {
+			seen[i] = true
+			l = append(l, vs[i])
+		}
+		return len(l) < len(vs)
+	})
+	return l
+}
+
+// sortBy returns a copy of vs stably sorted by less over the indexes of vs.
+func sortBy[T any](vs []T, less func(i, j int) bool) []T {
+	index := make([]int, len(vs))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		return less(index[i], index[j])
+	})
+	return permute(vs, index)
+}
+
+// permute returns a copy of vs in order of the indexes.
+func permute[T any](vs []T, index []int) []T {
+	l := make([]T, len(index))
+	for i, j := range index {
+		l[i] = vs[j]
+	}
+	return l
+}
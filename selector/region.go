@@ -0,0 +1,53 @@
+package selector
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/go-gost/core/selector"
+)
+
+type regionStrategy[T any] struct {
+	inner selector.Strategy[T]
+	last  string
+	mu    sync.Mutex
+}
+
+// RegionStrategy is a strategy for node selector, it spreads the load evenly across the regions.
+// The nodes are grouped by the region label (the nodes without the label belong to the region with empty name),
+// the regions are selected in round-robin order of their names,
+// then the least-conn strategy selects a node in the selected region.
+//
+// Only the regions having live nodes after filtering take part in the rotation,
+// so a region without live nodes is skipped and the rotation continues with the next region.
+func RegionStrategy[T any]() selector.Strategy[T] {
+	return &regionStrategy[T]{
+		inner: LeastConnStrategy[T](),
+	}
+}
+
+func (s *regionStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	groups := partition(vs, labelRegion)
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	s.mu.Lock()
+	// the next region after the last selected one, wrapping around.
+	g := groups[0]
+	for _, group := range groups {
+		if group.Name > s.last {
+			g = group
+			break
+		}
+	}
+	s.last = g.Name
+	s.mu.Unlock()
+
+	return s.inner.Apply(ctx, g.Nodes...)
+}
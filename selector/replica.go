@@ -0,0 +1,190 @@
+package selector
+
+import (
+	"context"
+	"hash/crc32"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+// ReplicaStrategy is implemented by the strategies which select multiple replicas for a key,
+// Apply selects the primary replica.
+type ReplicaStrategy[T any] interface {
+	Replicas(ctx context.Context, vs ...T) []T
+}
+
+type zoneReplicaHashStrategy[T any] struct {
+	options  strategyOptions
+	replicas int
+	rings    ringCache
+	r        *rand.Rand
+	mu       sync.Mutex
+}
+
+// ZoneReplicaHashStrategy is a consistent hash strategy for the replicated objects,
+// the key (see StrategyHashKeyOption) is mapped to the hash ring,
+// and the replicas are the next distinct objects clockwise on the ring.
+// The replicas are in distinct zones (the zone label) for fault tolerance,
+// the objects in the zones already chosen are skipped while walking the ring.
+// If there are not enough distinct zones, the rest of the replicas are the next distinct objects regardless of the zones.
+//
+// The key is chosen randomly if it is not available. The objects without the zone label belong to the zone with empty name.
+func ZoneReplicaHashStrategy[T any](replicas int, opts ...StrategyOption) selector.Strategy[T] {
+	if replicas <= 0 {
+		replicas = 1
+	}
+	options := newStrategyOptions(opts)
+	return &zoneReplicaHashStrategy[T]{
+		options:  options,
+		replicas: replicas,
+		r:        options.rand,
+	}
+}
+
+func (s *zoneReplicaHashStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if replicas := s.Replicas(ctx, vs...); len(replicas) > 0 {
+		v = replicas[0]
+	}
+	return
+}
+
+// Replicas implements ReplicaStrategy interface, the primary replica is the first one.
+func (s *zoneReplicaHashStrategy[T]) Replicas(ctx context.Context, vs ...T) []T {
+	return s.replicasOf(ctx, vs, s.replicas)
+}
+
+func (s *zoneReplicaHashStrategy[T]) replicasOf(ctx context.Context, vs []T, n int) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var key uint32
+	if k, ok := s.options.hashKey(ctx); ok {
+		key = crc32.ChecksumIEEE([]byte(k))
+	} else {
+		key = s.r.Uint32()
+	}
+	ring := s.rings.get(identities(vs))
+
+	if n > len(vs) {
+		n = len(vs)
+	}
+	chosen := make(map[int]bool, n)
+	zones := make(map[string]bool, n)
+	var replicas []T
+
+	ring.walk(key, func(i int) bool {
+		if zone := zoneOf(vs[i]); !chosen[i] && !zones[zone] {
+			chosen[i] = true
+			zones[zone] = true
+			replicas = append(replicas, vs[i])
+		}
+		return len(replicas) < n
+	})
+	// not enough distinct zones, fall back to the distinct objects.
+	if len(replicas) < n {
+		ring.walk(key, func(i int) bool {
+			if !chosen[i] {
+				chosen[i] = true
+				replicas = append(replicas, vs[i])
+			}
+			return len(replicas) < n
+		})
+	}
+
+	return replicas
+}
+
+type windowHashStrategy[T any] struct {
+	options strategyOptions
+	window  time.Duration
+	rings   ringCache
+	r       *rand.Rand
+	mu      sync.Mutex
+}
+
+// WindowHashStrategy is a consistent hash strategy with the time-windowed affinity.
+// The key (see StrategyHashKeyOption) is hashed together with the index of the current time window (now / window),
+// so a key sticks to a node within a window, and is rehashed to another node in the next window.
+// It spreads the long-term load of the hot keys while preserving the short-term locality.
+//
+// The window defaults to 1 minute, and the node is selected randomly if the key is not available.
+func WindowHashStrategy[T any](window time.Duration, opts ...StrategyOption) selector.Strategy[T] {
+	if window <= 0 {
+		window = time.Minute
+	}
+	options := newStrategyOptions(opts)
+	return &windowHashStrategy[T]{
+		options: options,
+		window:  window,
+		r:       options.rand,
+	}
+}
+
+func (s *windowHashStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.options.hashKey(ctx)
+	if !ok {
+		return vs[s.r.Intn(len(vs))]
+	}
+
+	window := time.Now().UnixNano() / int64(s.window)
+	hash := crc32.ChecksumIEEE([]byte(key + "/" + strconv.FormatInt(window, 10)))
+	if i := s.rings.get(identities(vs)).get(hash); i >= 0 {
+		return vs[i]
+	}
+	return vs[0]
+}
+
+type consistentHashStrategy[T any] struct {
+	options strategyOptions
+	rings   ringCache
+	r       *rand.Rand
+	mu      sync.Mutex
+}
+
+// ConsistentHashStrategy is a strategy for node selector by consistent hashing.
+// The nodes are placed on a hash ring with the number of virtual nodes of replicas (defaults to 100),
+// and the key (see StrategyHashKeyOption, the hash source in context by default) is mapped to the next node clockwise,
+// so adding or removing a node only remaps about 1/n of the keys.
+// The ring is rebuilt only when the set of the nodes changes, and the node is selected randomly if the key is not available.
+func ConsistentHashStrategy[T any](replicas int, opts ...StrategyOption) selector.Strategy[T] {
+	options := newStrategyOptions(opts)
+	return &consistentHashStrategy[T]{
+		options: options,
+		rings:   ringCache{vnodes: replicas},
+		r:       options.rand,
+	}
+}
+
+func (s *consistentHashStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.options.hashKey(ctx)
+	if !ok {
+		return vs[s.r.Intn(len(vs))]
+	}
+
+	if i := s.rings.get(identities(vs)).get(crc32.ChecksumIEEE([]byte(key))); i >= 0 {
+		return vs[i]
+	}
+	return vs[0]
+}
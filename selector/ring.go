@@ -0,0 +1,125 @@
+package selector
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// defaultVirtualNodes is the number of the points of each object on the hash ring.
+const defaultVirtualNodes = 100
+
+// hashRing is a consistent hash ring of the objects,
+// each object is placed on the ring as a number of virtual nodes by the hash of its identity.
+type hashRing struct {
+	hashes []uint32
+	// owners are the indexes of the objects owning the points.
+	owners []int
+}
+
+func newHashRing(ids []string, vnodes int) *hashRing {
+	if vnodes <= 0 {
+		vnodes = defaultVirtualNodes
+	}
+
+	type point struct {
+		hash  uint32
+		owner int
+	}
+	points := make([]point, 0, len(ids)*vnodes)
+	for i, id := range ids {
+		for j := 0; j < vnodes; j++ {
+			points = append(points, point{
+				hash:  crc32.ChecksumIEEE([]byte(id + "#" + strconv.Itoa(j))),
+				owner: i,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return points[i].owner < points[j].owner
+		}
+		return points[i].hash < points[j].hash
+	})
+
+	r := &hashRing{
+		hashes: make([]uint32, len(points)),
+		owners: make([]int, len(points)),
+	}
+	for i, p := range points {
+		r.hashes[i] = p.hash
+		r.owners[i] = p.owner
+	}
+	return r
+}
+
+// walk visits the owners of the points clockwise from the first point at or after key,
+// until fn returns false or all the points are visited.
+func (r *hashRing) walk(key uint32, fn func(owner int) bool) {
+	n := len(r.hashes)
+	if n == 0 {
+		return
+	}
+	start := sort.Search(n, func(i int) bool {
+		return r.hashes[i] >= key
+	})
+	for i := 0; i < n; i++ {
+		if !fn(r.owners[(start+i)%n]) {
+			return
+		}
+	}
+}
+
+// get returns the owner of key, it is -1 if the ring is empty.
+func (r *hashRing) get(key uint32) (owner int) {
+	owner = -1
+	r.walk(key, func(i int) bool {
+		owner = i
+		return false
+	})
+	return
+}
+
+// ringCache keeps the ring of the most recent object set,
+// the ring is rebuilt lazily when the fingerprint of the object set changes.
+// It is concurrency-unsafe, the owner should guard it with its own lock.
+type ringCache struct {
+	vnodes      int
+	fingerprint uint64
+	ring        *hashRing
+}
+
+func (c *ringCache) get(ids []string) *hashRing {
+	if fingerprint := fingerprintOf(ids); c.ring == nil || fingerprint != c.fingerprint {
+		c.ring = newHashRing(ids, c.vnodes)
+		c.fingerprint = fingerprint
+	}
+	return c.ring
+}
+
+// fingerprintOf returns the FNV-1a hash of the ids in order.
+func fingerprintOf(ids []string) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset)
+	for _, id := range ids {
+		for i := 0; i < len(id); i++ {
+			h ^= uint64(id[i])
+			h *= prime
+		}
+		// separator of the ids.
+		h ^= 0xff
+		h *= prime
+	}
+	return h
+}
+
+func identities[T any](vs []T) []string {
+	ids := make([]string, len(vs))
+	for i := range vs {
+		ids[i] = identity(vs[i])
+	}
+	return ids
+}
@@ -0,0 +1,151 @@
+package selector
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/selector"
+	mdutil "github.com/go-gost/x/metadata/util"
+)
+
+// ScoreTerm is a term of the composite score, it scores the objects by the numeric value of the Label in metadata.
+// If Lower is true, the lower value scores higher, e.g. for a cost label.
+type ScoreTerm struct {
+	Label  string
+	Weight float64
+	Lower  bool
+}
+
+type compositeMetadataStrategy[T any] struct {
+	terms []ScoreTerm
+}
+
+// CompositeMetadataStrategy is a strategy for node selector.
+// The node with the highest composite score is selected,
+// the composite score is the weighted sum of the normalized scores of the terms.
+//
+// Each term is normalized across the candidates by min-max to [0, 1],
+// so the terms of different scales are comparable, and the best candidate of a term scores 1.
+// A candidate without the label is treated as value 0,
+// and a term whose value is the same for all candidates contributes nothing.
+// The ties are broken by the order of the candidates, so the first one wins.
+func CompositeMetadataStrategy[T any](terms []ScoreTerm) selector.Strategy[T] {
+	return &compositeMetadataStrategy[T]{
+		terms: terms,
+	}
+}
+
+func (s *compositeMetadataStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	scores := s.scores(vs)
+	best := 0
+	for i := range scores {
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	return vs[best]
+}
+
+// scores returns the composite scores of vs.
+func (s *compositeMetadataStrategy[T]) scores(vs []T) []float64 {
+	scores := make([]float64, len(vs))
+	values := make([]float64, len(vs))
+	for _, term := range s.terms {
+		if term.Weight == 0 {
+			continue
+		}
+
+		for i := range vs {
+			values[i] = 0
+			if md, _ := any(vs[i]).(metadata.Metadatable); md != nil {
+				values[i] = mdutil.GetFloat(md.Metadata(), term.Label)
+			}
+		}
+
+		lo, hi := values[0], values[0]
+		for _, value := range values[1:] {
+			if value < lo {
+				lo = value
+			}
+			if value > hi {
+				hi = value
+			}
+		}
+		if hi == lo {
+			continue
+		}
+
+		for i, value := range values {
+			norm := (value - lo) / (hi - lo)
+			if term.Lower {
+				norm = 1 - norm
+			}
+			scores[i] += term.Weight * norm
+		}
+	}
+	return scores
+}
+
+type leastCostStrategy[T any] struct {
+	costFn func(T) float64
+	r      *rand.Rand
+	mu     sync.Mutex
+}
+
+// LeastCostStrategy is a strategy for node selector.
+// The node with the minimum estimated cost per request is selected, and randomly among the nodes of the same cost.
+// The cost is estimated by costFn, for example by the provider and egress tier labels of the node,
+// it defaults to the numeric value of the cost label, and the nodes without the label are free.
+func LeastCostStrategy[T any](costFn func(T) float64) selector.Strategy[T] {
+	if costFn == nil {
+		costFn = costOf[T]
+	}
+	return &leastCostStrategy[T]{
+		costFn: costFn,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *leastCostStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	costs := make([]float64, len(vs))
+	for i := range vs {
+		costs[i] = s.costFn(vs[i])
+	}
+
+	minCost := costs[0]
+	for _, cost := range costs[1:] {
+		if cost < minCost {
+			minCost = cost
+		}
+	}
+
+	var candidates []T
+	for i := range vs {
+		if costs[i] == minCost {
+			candidates = append(candidates, vs[i])
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return candidates[s.r.Intn(len(candidates))]
+}
+
+func costOf[T any](v T) float64 {
+	if md, _ := any(v).(metadata.Metadatable); md != nil {
+		return mdutil.GetFloat(md.Metadata(), labelCost)
+	}
+	return 0
+}
@@ -2,9 +2,15 @@ package selector
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-gost/core/selector"
+	xctx "github.com/go-gost/x/ctx"
 )
 
 // default options for FailFilter
@@ -18,26 +24,650 @@ const (
 	labelBackup      = "backup"
 	labelMaxFails    = "maxFails"
 	labelFailTimeout = "failTimeout"
+	labelGroup       = "group"
+	labelMinShare    = "minShare"
+	labelLocal       = "local"
+	labelZone        = "zone"
+	labelRegion      = "region"
+	labelVersion     = "version"
+	labelCost        = "cost"
+	// labelPriority is the health check priority of a node, a higher priority is checked more frequently.
+	labelPriority    = "priority"
+	labelMaintenance = "maintenance"
+	labelMaxConns    = "maxConns"
+	labelDefault     = "default"
+	labelDrain       = "drain"
+	// labelTier is the selection tier of a node, a lower tier is preferred by PriorityFilter.
+	labelTier = "tier"
 )
 
+type selectorOptions[T any] struct {
+	preSelect func(ctx context.Context, vs []T) []T
+	activity  ActivityNotifier
+	tracer    Tracer
+	def       *T
+	timing    bool
+	metrics   SelectorMetrics
+	stageHook func(stage string, before, after int)
+}
+
+type SelectorOption[T any] func(*selectorOptions[T])
+
+// SelectorPreSelectOption sets a hook to rewrite the candidates,
+// it is applied after the filters and before the strategy.
+// If the hook returns an empty slice, nothing will be selected.
+func SelectorPreSelectOption[T any](fn func(ctx context.Context, vs []T) []T) SelectorOption[T] {
+	return func(opts *selectorOptions[T]) {
+		opts.preSelect = fn
+	}
+}
+
+var (
+	ErrNoAvailable = errors.New("selector: no available object")
+)
+
+// NoAvailableError is returned when no object is available for selection,
+// Filter is the name of the filter that emptied the candidates.
+type NoAvailableError struct {
+	Filter string
+	Reason string
+}
+
+func (e *NoAvailableError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%v: filtered by %s", ErrNoAvailable, e.Filter)
+	}
+	return fmt.Sprintf("%v: filtered by %s: %s", ErrNoAvailable, e.Filter, e.Reason)
+}
+
+func (e *NoAvailableError) Unwrap() error {
+	return ErrNoAvailable
+}
+
+// ExhaustReporter is implemented by the filters which can report why they filtered all the objects.
+type ExhaustReporter interface {
+	ExhaustReason() (name string, reason string)
+}
+
+// TrySelector is implemented by the selectors
+// which report the failure of the selection as an error.
+type TrySelector[T any] interface {
+	// TrySelect selects an object as Select does,
+	// an error wrapping ErrNoAvailable is returned if no object is available.
+	TrySelect(ctx context.Context, vs ...T) (T, error)
+}
+
+// TrySelect selects an object from vs by sel, an error wrapping ErrNoAvailable is returned if no object is available,
+// so the callers can tell an empty candidate set from a selected object which is the zero value.
+//
+// The error of TrySelect is returned if sel implements TrySelector,
+// otherwise the selection of the zero value is treated as no object available.
+func TrySelect[T any](ctx context.Context, sel selector.Selector[T], vs ...T) (v T, err error) {
+	if sel == nil {
+		return v, ErrNoAvailable
+	}
+	if ts, ok := sel.(TrySelector[T]); ok {
+		return ts.TrySelect(ctx, vs...)
+	}
+	if v = sel.Select(ctx, vs...); isZero(v) {
+		return v, ErrNoAvailable
+	}
+	return v, nil
+}
+
+// HedgeSelector is implemented by the selectors which can select a hedge object for request hedging.
+type HedgeSelector[T any] interface {
+	// SelectHedge selects a primary object and a distinct hedge object,
+	// ok is false if no hedge object is available.
+	SelectHedge(ctx context.Context, vs ...T) (primary, hedge T, ok bool)
+}
+
+// Outcome is the result of using a selected object.
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	// OutcomeFailure is a soft failure, such as a timeout, it counts once towards the failure thresholds.
+	OutcomeFailure
+	// OutcomeHardFailure is a failure which is more likely caused by a dead object, such as a refused connection,
+	// it counts as the hard failure weight of FailFilter (see FailFilterHardFailWeightOption).
+	OutcomeHardFailure
+)
+
+// ClassifyError returns the outcome of err for ReportError:
+// OutcomeSuccess for nil, OutcomeHardFailure for the refused connections and the unreachable hosts or networks,
+// and OutcomeFailure for the rest, including the timeouts.
+func ClassifyError(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH):
+		return OutcomeHardFailure
+	default:
+		return OutcomeFailure
+	}
+}
+
+// SelectObserver is implemented by the filters which track the objects selected by the strategy,
+// such as BreakerFilter counting the half-open trials.
+type SelectObserver[T any] interface {
+	Selected(v T)
+}
+
+// FailWeigher is implemented by the filters which weight the failures reported to the selector,
+// the marker of the object is marked by the greatest weight of the filters, at least once.
+type FailWeigher interface {
+	FailWeight(outcome Outcome) int
+}
+
+// Reporter receives the outcomes of the selected objects,
+// it is the feedback channel for the adaptive strategies.
+//
+// The selector created by NewSelector implements Reporter, updates the object markers
+// and forwards the reports to its strategy and filters, the built-in strategies consuming the reports are:
+//   - ErrorRateStrategy: updates the error rate of the object.
+type Reporter[T any] interface {
+	Report(v T, outcome Outcome, latency time.Duration)
+}
+
+// ErrorReporter is the passive health checking interface, the outcome of the object is derived from err.
+//
+// The selector created by NewSelector implements ErrorReporter,
+// the marker of the reported object is marked on failure and reset on success,
+// so that the FailFilter and HealthCheckFilter react to the real traffic without an active prober.
+type ErrorReporter[T any] interface {
+	ReportError(v T, err error, latency time.Duration)
+}
+
+// Degradable is implemented by the selectors which can report the degraded state.
+type Degradable interface {
+	// Degraded reports whether the most recent selection is served without any primary (non-backup) object.
+	Degraded() bool
+}
+
+// ActivityNotifier is notified when the selector is used, such as the HealthChecker in lazy mode.
+type ActivityNotifier interface {
+	Touch()
+}
+
+// SelectorActivityOption sets the notifier which is touched on each selection.
+func SelectorActivityOption[T any](n ActivityNotifier) SelectorOption[T] {
+	return func(opts *selectorOptions[T]) {
+		opts.activity = n
+	}
+}
+
+// Tracer records the selection decisions, for example as the spans of OpenTelemetry,
+// the span of the request can be obtained from ctx.
+type Tracer interface {
+	// TraceSelect is called on each selection with the name of the strategy,
+	// the number of the candidates after filtering, the selected object (nil if none) and the error if any.
+	TraceSelect(ctx context.Context, strategy string, candidates int, selected any, err error)
+}
+
+// SelectorTracerOption sets the tracer which is invoked on each selection.
+func SelectorTracerOption[T any](tracer Tracer) SelectorOption[T] {
+	return func(opts *selectorOptions[T]) {
+		opts.tracer = tracer
+	}
+}
+
+// SelectorDefaultOption sets the default object which is selected when nothing else can be selected,
+// for example all the objects are filtered, so the selection always resolves to a known object.
+// The default object bypasses the filters, and the selection is reported as degraded.
+func SelectorDefaultOption[T any](v T) SelectorOption[T] {
+	return func(opts *selectorOptions[T]) {
+		opts.def = &v
+	}
+}
+
+// SelectorTimingOption enables recording the time taken by each selection,
+// the distribution is exposed by the SelectTimer interface. It is disabled by default.
+func SelectorTimingOption[T any](b bool) SelectorOption[T] {
+	return func(opts *selectorOptions[T]) {
+		opts.timing = b
+	}
+}
+
+// SelectorFilterStageHookOption sets the hook which records the filter stages of each selection,
+// it is called after each filter with the name of the filter (see ExhaustReporter) as the stage,
+// and the numbers of the candidates before and after the filter, the pre-select hook is the stage preSelect.
+// The stages after a filter empties the candidates are skipped. It is nil by default and nothing is recorded.
+// Unlike the Tracer (see SelectorTracerOption) which records the result of each selection, the hook records its steps.
+func SelectorFilterStageHookOption[T any](fn func(stage string, before, after int)) SelectorOption[T] {
+	return func(opts *selectorOptions[T]) {
+		opts.stageHook = fn
+	}
+}
+
+// SelectorMetrics receives the counters of the selector, for example to be exported as Prometheus counters.
+// The methods are called inline in the selection, so they should be fast.
+type SelectorMetrics interface {
+	// IncSelect is called when v is selected by the strategy.
+	IncSelect(v any)
+	// IncFail is called when the marker of v is marked by a failure reported to the selector.
+	IncFail(v any)
+	// IncFiltered is called when v is dropped by the filter.
+	IncFiltered(v any, filter string)
+}
+
+// SelectorMetricsOption sets the metrics sink of the selector, it is nil by default and no metrics are collected.
+func SelectorMetricsOption[T any](m SelectorMetrics) SelectorOption[T] {
+	return func(opts *selectorOptions[T]) {
+		opts.metrics = m
+	}
+}
+
+// StrategySetter is implemented by the selectors whose strategy can be replaced at runtime.
+type StrategySetter[T any] interface {
+	// SetStrategy replaces the strategy atomically, the filters and the object markers are preserved.
+	SetStrategy(strategy selector.Strategy[T])
+}
+
 type defaultSelector[T any] struct {
-	strategy selector.Strategy[T]
+	strategy atomic.Pointer[selector.Strategy[T]]
 	filters  []selector.Filter[T]
+	options  selectorOptions[T]
+	degraded atomic.Bool
+	timing   latencyRecorder
 }
 
 func NewSelector[T any](strategy selector.Strategy[T], filters ...selector.Filter[T]) selector.Selector[T] {
-	return &defaultSelector[T]{
-		filters:  filters,
-		strategy: strategy,
+	return NewSelectorWithOptions(strategy, filters)
+}
+
+// NewSelectorWithOptions creates a selector as NewSelector does, with the extra options applied.
+func NewSelectorWithOptions[T any](strategy selector.Strategy[T], filters []selector.Filter[T], opts ...SelectorOption[T]) selector.Selector[T] {
+	s := &defaultSelector[T]{
+		filters: filters,
+	}
+	s.SetStrategy(strategy)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&s.options)
+		}
 	}
+	return s
+}
+
+func (s *defaultSelector[T]) SetStrategy(strategy selector.Strategy[T]) {
+	if strategy == nil {
+		strategy = RoundRobinStrategy[T]()
+	}
+	s.strategy.Store(&strategy)
+}
+
+func (s *defaultSelector[T]) getStrategy() selector.Strategy[T] {
+	return *s.strategy.Load()
 }
 
 func (s *defaultSelector[T]) Select(ctx context.Context, vs ...T) (v T) {
+	v, _ = s.TrySelect(ctx, vs...)
+	return
+}
+
+func (s *defaultSelector[T]) TrySelect(ctx context.Context, vs ...T) (v T, err error) {
+	if s.options.timing {
+		defer func(start time.Time) {
+			s.timing.record(time.Since(start))
+		}(time.Now())
+	}
+
+	strategy := s.getStrategy()
+
+	if tracer := s.options.tracer; tracer != nil {
+		defer func() {
+			var selected any
+			if err == nil {
+				selected = v
+			}
+			tracer.TraceSelect(ctx, strategyName(strategy), len(vs), selected, err)
+		}()
+	}
+
+	if vs, err = s.filter(ctx, vs); err != nil {
+		if s.options.def != nil {
+			return *s.options.def, nil
+		}
+		return
+	}
+
+	v = strategy.Apply(ctx, vs...)
+	if isZero(v) && s.options.def != nil {
+		s.degraded.Store(true)
+		return *s.options.def, nil
+	}
+	if !isZero(v) {
+		s.selected(v)
+	}
+	if visited := xctx.VisitedFromContext(ctx); visited != nil && !isZero(v) {
+		visited.Add(identity(v))
+	}
+	return v, nil
+}
+
+// strategyName returns the name of the strategy, it is the type name if the strategy has no name.
+func strategyName(strategy any) string {
+	if n, ok := strategy.(namer); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", strategy)
+}
+
+// filter applies the filters and the pre-select hook to vs,
+// and updates the activity and degraded state of the selector.
+func (s *defaultSelector[T]) filter(ctx context.Context, vs []T) (_ []T, err error) {
+	defer func() {
+		if err != nil {
+			s.degraded.Store(true)
+		}
+	}()
+
+	if s.options.activity != nil {
+		s.options.activity.Touch()
+	}
+
+	if len(vs) == 0 {
+		return nil, ErrNoAvailable
+	}
+
 	for _, filter := range s.filters {
+		in := vs
 		vs = filter.Filter(ctx, vs...)
+		if s.options.stageHook != nil {
+			s.options.stageHook(filterName(filter), len(in), len(vs))
+		}
+		if s.options.metrics != nil && len(vs) < len(in) {
+			s.countFiltered(filter, in, vs)
+		}
+		if len(vs) == 0 {
+			return nil, exhaustError(filter)
+		}
 	}
-	if len(vs) == 0 {
+	if s.options.preSelect != nil {
+		n := len(vs)
+		vs = s.options.preSelect(ctx, vs)
+		if s.options.stageHook != nil {
+			s.options.stageHook("preSelect", n, len(vs))
+		}
+		if len(vs) == 0 {
+			return nil, &NoAvailableError{Filter: "preSelect"}
+		}
+	}
+	s.degraded.Store(!hasPrimary(vs))
+
+	return vs, nil
+}
+
+// countFiltered reports the objects in in but not in out as filtered by filter.
+func (s *defaultSelector[T]) countFiltered(filter selector.Filter[T], in, out []T) {
+	kept := make(map[string]struct{}, len(out))
+	for _, v := range out {
+		kept[identity(v)] = struct{}{}
+	}
+	name := filterName(filter)
+	for _, v := range in {
+		if _, ok := kept[identity(v)]; !ok {
+			s.options.metrics.IncFiltered(v, name)
+		}
+	}
+}
+
+// SelectHedge selects the primary by the strategy, then the hedge is selected by the same strategy
+// from the rest of the filtered objects, so it is the next-best object by the criteria of the strategy.
+// Note that the stateful strategies count the hedge selection as well as the primary one.
+func (s *defaultSelector[T]) SelectHedge(ctx context.Context, vs ...T) (primary, hedge T, ok bool) {
+	strategy := s.getStrategy()
+
+	vs, err := s.filter(ctx, vs)
+	if err != nil {
+		return
+	}
+
+	primary = strategy.Apply(ctx, vs...)
+	if !isZero(primary) {
+		s.selected(primary)
+	}
+	id := identity(primary)
+
+	var rest []T
+	for _, v := range vs {
+		if identity(v) != id {
+			rest = append(rest, v)
+		}
+	}
+	if len(rest) == 0 {
 		return
 	}
-	return s.strategy.Apply(ctx, vs...)
+
+	hedge = strategy.Apply(ctx, rest...)
+	ok = !isZero(hedge)
+	if ok {
+		s.selected(hedge)
+	}
+	return
+}
+
+// selected notifies the metrics and the filters implementing SelectObserver of the selection of v.
+func (s *defaultSelector[T]) selected(v T) {
+	if m := s.options.metrics; m != nil {
+		m.IncSelect(v)
+	}
+	for _, filter := range s.filters {
+		if so, ok := filter.(SelectObserver[T]); ok {
+			so.Selected(v)
+		}
+	}
+}
+
+// Explainer is implemented by the selectors which can explain why an object is not selected.
+type Explainer[T any] interface {
+	// ExplainNode runs the filters of the selector against the candidates vs (v is added if absent),
+	// and reports whether v survives the filters, or the name of the first filter excluding it.
+	// The candidates matter since some filters depend on the others, e.g. BackupFilter keeps the backups
+	// only if no primary exists, so vs should be the candidates of the selection in question.
+	// It does not change the state of the filters, the filters implementing Peeker are run by Peek,
+	// while the pre-select hook (see SelectorPreSelectOption) is run as is.
+	ExplainNode(ctx context.Context, v T, vs ...T) (eligible bool, rejectedBy string)
+}
+
+// Peeker is implemented by the stateful filters, such as FailFilter with cooldown, BreakerFilter and SlowStartFilter.
+// Peek filters vs as Filter does but does not change the state of the filter, it is used by ExplainNode.
+type Peeker[T any] interface {
+	Peek(ctx context.Context, vs ...T) []T
+}
+
+func (s *defaultSelector[T]) ExplainNode(ctx context.Context, v T, vs ...T) (bool, string) {
+	id := identity(v)
+	contains := func(vs []T) bool {
+		for i := range vs {
+			if identity(vs[i]) == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !contains(vs) {
+		vs = append(vs[:len(vs):len(vs)], v)
+	}
+
+	for _, filter := range s.filters {
+		if p, ok := filter.(Peeker[T]); ok {
+			vs = p.Peek(ctx, vs...)
+		} else {
+			vs = filter.Filter(ctx, vs...)
+		}
+		if !contains(vs) {
+			return false, filterName(filter)
+		}
+	}
+	if s.options.preSelect != nil {
+		if vs = s.options.preSelect(ctx, vs); !contains(vs) {
+			return false, "preSelect"
+		}
+	}
+	return true, ""
+}
+
+// filterName returns the name of the filter, it is the type name if the filter has no name.
+func filterName(filter any) string {
+	switch f := filter.(type) {
+	case ExhaustReporter:
+		name, _ := f.ExhaustReason()
+		return name
+	case namer:
+		return f.Name()
+	}
+	return fmt.Sprintf("%T", filter)
+}
+
+func exhaustError(filter any) error {
+	e := &NoAvailableError{}
+	if r, ok := filter.(ExhaustReporter); ok {
+		e.Filter, e.Reason = r.ExhaustReason()
+	} else {
+		e.Filter = fmt.Sprintf("%T", filter)
+	}
+	return e
+}
+
+// Report updates the marker of v by the outcome,
+// then forwards the outcome to the strategy and filters which implement Reporter.
+func (s *defaultSelector[T]) Report(v T, outcome Outcome, latency time.Duration) {
+	if marker := markerOf(v); marker != nil {
+		if outcome == OutcomeSuccess {
+			marker.Reset()
+		} else {
+			for n := s.failWeight(outcome); n > 0; n-- {
+				marker.Mark()
+			}
+			if m := s.options.metrics; m != nil {
+				m.IncFail(v)
+			}
+		}
+	}
+
+	if r, ok := s.getStrategy().(Reporter[T]); ok {
+		r.Report(v, outcome, latency)
+	}
+	for _, filter := range s.filters {
+		if r, ok := filter.(Reporter[T]); ok {
+			r.Report(v, outcome, latency)
+		}
+	}
+}
+
+// failWeight returns the number of the marks of a failure of outcome by the filters implementing FailWeigher.
+func (s *defaultSelector[T]) failWeight(outcome Outcome) int {
+	n := 1
+	for _, filter := range s.filters {
+		if fw, ok := filter.(FailWeigher); ok {
+			n = max(n, fw.FailWeight(outcome))
+		}
+	}
+	return n
+}
+
+// ReportError reports the outcome of v classified from err by ClassifyError.
+func (s *defaultSelector[T]) ReportError(v T, err error, latency time.Duration) {
+	s.Report(v, ClassifyError(err), latency)
+}
+
+// ExportState exports the state of the strategy if it implements StateTransferer, otherwise nil is returned.
+func (s *defaultSelector[T]) ExportState() []byte {
+	if st, ok := s.getStrategy().(StateTransferer); ok {
+		return st.ExportState()
+	}
+	return nil
+}
+
+// ImportState imports the state to the strategy if it implements StateTransferer.
+func (s *defaultSelector[T]) ImportState(data []byte) error {
+	if st, ok := s.getStrategy().(StateTransferer); ok && len(data) > 0 {
+		return st.ImportState(data)
+	}
+	return nil
+}
+
+// SelectLatency implements SelectTimer interface, the histogram is empty if the timing is disabled.
+func (s *defaultSelector[T]) SelectLatency() LatencyHistogram {
+	return s.timing.snapshot()
+}
+
+// Confirm confirms the connection to v by the strategy if it implements Confirmer.
+func (s *defaultSelector[T]) Confirm(v T) {
+	if c, ok := s.getStrategy().(Confirmer[T]); ok {
+		c.Confirm(v)
+	}
+}
+
+// Cancel cancels the selection of v by the strategy if it implements Confirmer.
+func (s *defaultSelector[T]) Cancel(v T) {
+	if c, ok := s.getStrategy().(Confirmer[T]); ok {
+		c.Cancel(v)
+	}
+}
+
+// SetDrain drains or undrains v by the filters implementing Drainer, see DrainFilter.
+func (s *defaultSelector[T]) SetDrain(v T, drain bool) {
+	for _, filter := range s.filters {
+		if d, ok := filter.(Drainer[T]); ok {
+			d.SetDrain(v, drain)
+		}
+	}
+}
+
+func (s *defaultSelector[T]) Degraded() bool {
+	return s.degraded.Load()
+}
+
+func hasPrimary[T any](vs []T) bool {
+	for _, v := range vs {
+		if !isBackup(v) && !isDefault(v) && tierOf(v) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type failoverSelector[T any] struct {
+	pools []selector.Selector[T]
+}
+
+// FailoverSelector composes multiple selectors as pools with failover between them.
+// The pools are tried in order, and the first non-empty selection is returned,
+// so a pool is used only when all the previous pools are exhausted.
+//
+// Each pool receives all the candidates, and narrows them down to its own members by its filters.
+// A pool is treated as exhausted when it selects nothing (the zero value),
+// or its TrySelect returns an error if it implements TrySelector.
+func FailoverSelector[T any](pools ...selector.Selector[T]) selector.Selector[T] {
+	return &failoverSelector[T]{
+		pools: pools,
+	}
+}
+
+func (s *failoverSelector[T]) Select(ctx context.Context, vs ...T) (v T) {
+	v, _ = s.TrySelect(ctx, vs...)
+	return
+}
+
+func (s *failoverSelector[T]) TrySelect(ctx context.Context, vs ...T) (v T, err error) {
+	err = ErrNoAvailable
+	for _, pool := range s.pools {
+		if pool == nil {
+			continue
+		}
+		if v, err = TrySelect(ctx, pool, vs...); err == nil {
+			return
+		}
+	}
+	return v, err
+}
+
+func isZero[T any](v T) bool {
+	return reflect.ValueOf(&v).Elem().IsZero()
 }
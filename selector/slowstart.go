@@ -0,0 +1,122 @@
+package selector
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+// minSlowStartRamp is the admission probability of an object at the start of the warmup.
+const minSlowStartRamp = 0.01
+
+// slowStartRamp returns the admission probability of an object recovered for elapsed,
+// it increases linearly from minSlowStartRamp to 1 over the warmup.
+func slowStartRamp(elapsed, warmup time.Duration) float64 {
+	if warmup <= 0 || elapsed >= warmup {
+		return 1
+	}
+	if elapsed <= 0 {
+		return minSlowStartRamp
+	}
+	return max(float64(elapsed)/float64(warmup), minSlowStartRamp)
+}
+
+type slowStartState struct {
+	failed    bool
+	recovered time.Time
+}
+
+type slowStartFilter[T any] struct {
+	warmup time.Duration
+	states map[string]*slowStartState
+	// started reports whether the initial objects are seen, they are not warmed up.
+	started bool
+	now     func() time.Time
+	r       *rand.Rand
+	mu      sync.Mutex
+}
+
+// SlowStartFilter ramps up the traffic to the recovered objects over the warmup,
+// so a recovered object is not overloaded again by its full share immediately.
+// An object recovers when its marker is reset after a failure, or when it is added after the first selection.
+//
+// The recovering object is admitted with probability elapsed/warmup (at least 1%),
+// which scales its share of the selections linearly from near zero to its full weight.
+// If no object is admitted, all the objects are returned.
+func SlowStartFilter[T any](warmup time.Duration) selector.Filter[T] {
+	return &slowStartFilter[T]{
+		warmup: warmup,
+		states: make(map[string]*slowStartState),
+		now:    time.Now,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Filter filters the recovering objects by their ramps.
+func (f *slowStartFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if f.warmup <= 0 || len(vs) == 0 {
+		return vs
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	var l []T
+	for _, v := range vs {
+		if f.admit(v, now) {
+			l = append(l, v)
+		}
+	}
+	f.started = true
+
+	if len(l) == 0 {
+		return vs
+	}
+	return l
+}
+
+// Peek implements Peeker interface, all the objects are kept,
+// since every recovering object is admitted with a probability of at least 1%.
+func (f *slowStartFilter[T]) Peek(ctx context.Context, vs ...T) []T {
+	return vs
+}
+
+func (f *slowStartFilter[T]) admit(v T, now time.Time) bool {
+	failed := false
+	if marker := markerOf(v); marker != nil {
+		failed = marker.Count() > 0
+	}
+
+	id := identity(v)
+	st := f.states[id]
+	if st == nil {
+		st = &slowStartState{failed: failed}
+		if f.started {
+			st.recovered = now
+		}
+		f.states[id] = st
+	}
+
+	if st.failed && !failed {
+		st.recovered = now
+	}
+	st.failed = failed
+
+	if st.recovered.IsZero() {
+		return true
+	}
+	ramp := slowStartRamp(now.Sub(st.recovered), f.warmup)
+	if ramp >= 1 {
+		st.recovered = time.Time{}
+		return true
+	}
+	return f.r.Float64() < ramp
+}
+
+func (f *slowStartFilter[T]) Name() string {
+	return "slowStart"
+}
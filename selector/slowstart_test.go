@@ -0,0 +1,77 @@
+package selector
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSlowStartRamp(t *testing.T) {
+	const warmup = 10 * time.Second
+
+	tests := []struct {
+		elapsed time.Duration
+		ramp    float64
+	}{
+		{0, minSlowStartRamp},
+		{warmup / 1000, minSlowStartRamp},
+		{warmup / 4, 0.25},
+		{warmup / 2, 0.5},
+		{warmup, 1},
+		{2 * warmup, 1},
+	}
+	for _, tt := range tests {
+		if got := slowStartRamp(tt.elapsed, warmup); got != tt.ramp {
+			t.Errorf("elapsed %s: got ramp %.4f, expected %.4f", tt.elapsed, got, tt.ramp)
+		}
+	}
+}
+
+func TestSlowStartFilter(t *testing.T) {
+	const (
+		warmup = 10 * time.Second
+		n      = 10000
+	)
+
+	now := time.Now()
+	f := SlowStartFilter[*testNode](warmup).(*slowStartFilter[*testNode])
+	f.now = func() time.Time { return now }
+	f.r = rand.New(rand.NewSource(1))
+
+	a := newTestNode("a", nil)
+	b := newTestNode("b", nil)
+	ctx := context.Background()
+
+	// the initial objects are not warmed up.
+	if got := f.Filter(ctx, a, b); len(got) != 2 {
+		t.Fatalf("got %d initial objects, expected 2", len(got))
+	}
+
+	a.marker.Mark()
+	f.Filter(ctx, a, b)
+	a.marker.Reset()
+	f.Filter(ctx, a, b)
+
+	admitted := func() int {
+		count := 0
+		for i := 0; i < n; i++ {
+			for _, v := range f.Filter(ctx, a, b) {
+				if v == a {
+					count++
+				}
+			}
+		}
+		return count
+	}
+
+	now = now.Add(warmup / 2)
+	if got := float64(admitted()) / n; got < 0.45 || got > 0.55 {
+		t.Errorf("got admission %.4f at half of the warmup, expected 0.5", got)
+	}
+
+	now = now.Add(warmup / 2)
+	if got := admitted(); got != n {
+		t.Errorf("got %d admissions after the warmup, expected %d", got, n)
+	}
+}
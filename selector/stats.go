@@ -0,0 +1,188 @@
+package selector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// NodeStats is the per-object view of an adaptive strategy.
+type NodeStats struct {
+	// Latency is the smoothed latency.
+	Latency time.Duration
+	// ActiveConns is the active connections seen in the last selection.
+	ActiveConns int64
+	// Pending is the selections not confirmed yet.
+	Pending int64
+	// ErrorRate is the recent error rate in range [0, 1].
+	ErrorRate float64
+	// Selections is the number of times the object is selected.
+	Selections uint64
+}
+
+// NodeStater is implemented by the adaptive strategies to expose their internal per-object states,
+// the stats are keyed by object identity.
+type NodeStater interface {
+	Stats() map[string]NodeStats
+}
+
+// nodeStats is a concurrency-unsafe collection of the object stats,
+// the owner should guard it with its own lock.
+type nodeStats map[string]*NodeStats
+
+func (m nodeStats) get(id string) *NodeStats {
+	st := m[id]
+	if st == nil {
+		st = &NodeStats{}
+		m[id] = st
+	}
+	return st
+}
+
+func (m nodeStats) snapshot() map[string]NodeStats {
+	stats := make(map[string]NodeStats, len(m))
+	for id, st := range m {
+		stats[id] = *st
+	}
+	return stats
+}
+
+// stateVersion is the version of the serialization format of the strategy state.
+const stateVersion = 1
+
+// StateTransferer is implemented by the stateful strategies,
+// so that the learned state can be transferred to the new instance on reload, avoiding the cold start.
+//
+// The state of an object is keyed by its identity, the states of the objects
+// which are absent after reload are ignored, and the new objects start with the initial state.
+type StateTransferer interface {
+	ExportState() []byte
+	// ImportState restores the exported state, the current state of the objects in data is replaced.
+	// An error is returned if data is of another version or exported by another kind of strategy.
+	ImportState(data []byte) error
+}
+
+type strategyState struct {
+	Version  int                  `json:"version"`
+	Strategy string               `json:"strategy"`
+	Nodes    map[string]nodeState `json:"nodes"`
+}
+
+// nodeState is the learned part of NodeStats, the transient counters such as pending are not transferred.
+type nodeState struct {
+	Latency    time.Duration `json:"latency,omitempty"`
+	ErrorRate  float64       `json:"errorRate,omitempty"`
+	Selections uint64        `json:"selections,omitempty"`
+}
+
+func (m nodeStats) export(strategy string) []byte {
+	state := strategyState{
+		Version:  stateVersion,
+		Strategy: strategy,
+		Nodes:    make(map[string]nodeState, len(m)),
+	}
+	for id, st := range m {
+		state.Nodes[id] = nodeState{
+			Latency:    st.Latency,
+			ErrorRate:  st.ErrorRate,
+			Selections: st.Selections,
+		}
+	}
+	data, _ := json.Marshal(state)
+	return data
+}
+
+func (m nodeStats) load(strategy string, data []byte) error {
+	var state strategyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state.Version != stateVersion {
+		return fmt.Errorf("unsupported state version %d", state.Version)
+	}
+	if state.Strategy != strategy {
+		return fmt.Errorf("state of strategy %s can not be imported to %s", state.Strategy, strategy)
+	}
+
+	for id, ns := range state.Nodes {
+		st := m.get(id)
+		st.Latency = ns.Latency
+		st.ErrorRate = ns.ErrorRate
+		st.Selections = ns.Selections
+	}
+	return nil
+}
+
+// histogramBuckets is the number of the buckets of LatencyHistogram,
+// the upper bound of bucket i is 2^i microseconds, and the last bucket is unbounded.
+const histogramBuckets = 22
+
+// LatencyHistogram is a snapshot of the latency distribution in exponential buckets.
+type LatencyHistogram struct {
+	// Bounds are the upper bounds of the buckets, the last bucket has no upper bound.
+	Bounds []time.Duration
+	// Counts are the numbers of the samples in the buckets.
+	Counts []uint64
+	// Total is the number of all the samples.
+	Total uint64
+}
+
+// Percentile returns the upper bound of the bucket containing the q-th quantile (q in range [0, 1]),
+// it is the bound of the last bounded bucket if the quantile falls into the unbounded bucket.
+func (h LatencyHistogram) Percentile(q float64) time.Duration {
+	if h.Total == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(h.Total))
+	var n uint64
+	for i, count := range h.Counts {
+		n += count
+		if n > rank || n == h.Total {
+			if i >= len(h.Bounds) {
+				i = len(h.Bounds) - 1
+			}
+			return h.Bounds[i]
+		}
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// SelectTimer is implemented by the selectors which record the time taken by the selections,
+// that is the cost of the decision of the selector itself, excluding the use of the selected object.
+type SelectTimer interface {
+	SelectLatency() LatencyHistogram
+}
+
+// latencyRecorder is a lock-free histogram of the latencies.
+type latencyRecorder struct {
+	counts [histogramBuckets]atomic.Uint64
+}
+
+func (r *latencyRecorder) record(d time.Duration) {
+	i := 0
+	if us := d.Microseconds(); us > 0 {
+		i = bits.Len64(uint64(us - 1))
+	}
+	if i >= histogramBuckets {
+		i = histogramBuckets - 1
+	}
+	r.counts[i].Add(1)
+}
+
+func (r *latencyRecorder) snapshot() LatencyHistogram {
+	h := LatencyHistogram{
+		Bounds: make([]time.Duration, histogramBuckets-1),
+		Counts: make([]uint64, histogramBuckets),
+	}
+	for i := range h.Bounds {
+		h.Bounds[i] = time.Duration(1<<i) * time.Microsecond
+	}
+	for i := range r.counts {
+		h.Counts[i] = r.counts[i].Load()
+		h.Total += h.Counts[i]
+	}
+	return h
+}
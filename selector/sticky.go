@@ -0,0 +1,91 @@
+package selector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+type stickyEntry struct {
+	id      string
+	expires time.Time
+}
+
+type stickyStrategy[T any] struct {
+	ttl      time.Duration
+	options  strategyOptions
+	fallback selector.Strategy[T]
+	sessions map[string]stickyEntry
+	swept    time.Time
+	mu       sync.Mutex
+}
+
+// StickyStrategy is a strategy for node selector for the stateful backends.
+// The session key (see StrategyHashKeyOption, the hash source in context by default) is mapped to the selected node,
+// and the requests of the session stick to the node until the session is idle for ttl (defaults to 5m).
+// The new sessions and the requests without the session key are selected by round-robin,
+// and a session is remapped to a fresh node if its node is no longer available.
+//
+// The expired sessions are evicted at most once per ttl, so the mapping does not grow unbounded.
+func StickyStrategy[T any](ttl time.Duration, opts ...StrategyOption) selector.Strategy[T] {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return &stickyStrategy[T]{
+		ttl:      ttl,
+		options:  newStrategyOptions(opts),
+		fallback: RoundRobinStrategy[T](),
+		sessions: make(map[string]stickyEntry),
+		swept:    time.Now(),
+	}
+}
+
+func (s *stickyStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	key, ok := s.options.hashKey(ctx)
+	if !ok {
+		return s.fallback.Apply(ctx, vs...)
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	if entry, ok := s.sessions[key]; ok && now.Before(entry.expires) {
+		for i := range vs {
+			if identity(vs[i]) == entry.id {
+				entry.expires = now.Add(s.ttl)
+				s.sessions[key] = entry
+				return vs[i]
+			}
+		}
+	}
+
+	v = s.fallback.Apply(ctx, vs...)
+	s.sessions[key] = stickyEntry{
+		id:      identity(v),
+		expires: now.Add(s.ttl),
+	}
+	return
+}
+
+func (s *stickyStrategy[T]) sweep(now time.Time) {
+	if now.Sub(s.swept) < s.ttl {
+		return
+	}
+	s.swept = now
+
+	for key, entry := range s.sessions {
+		if !now.Before(entry.expires) {
+			delete(s.sessions, key)
+		}
+	}
+}
@@ -2,13 +2,17 @@ package selector
 
 import (
 	"context"
+	"fmt"
 	"hash/crc32"
 	"math"
 	"math/rand"
+	"reflect"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/core/metadata"
 	"github.com/go-gost/core/selector"
@@ -24,6 +28,49 @@ type LatencyStater interface {
 	Latency() time.Duration
 }
 
+// LatencySetter is implemented by the objects whose latency can be fed by the active probes,
+// the HealthChecker sets the latency of the passing probes, so that the latency based strategies
+// can use the probe data with LatencyStater.
+type LatencySetter interface {
+	SetLatency(d time.Duration)
+}
+
+// Identifiable is an object which has a stable identity,
+// the identity is used as the key of the per-object state kept by strategies and filters.
+type Identifiable interface {
+	ID() string
+}
+
+type namer interface {
+	Name() string
+}
+
+// identity returns the identity of v.
+// The node name is used for *chain.Node, falling back to its address.
+func identity(v any) string {
+	switch t := v.(type) {
+	case Identifiable:
+		return t.ID()
+	case *chain.Node:
+		if t == nil {
+			return ""
+		}
+		if t.Name != "" {
+			return t.Name
+		}
+		return t.Addr
+	case namer:
+		return t.Name()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Chan, reflect.Func, reflect.Slice, reflect.UnsafePointer:
+		return fmt.Sprintf("%p", v)
+	}
+	return fmt.Sprint(v)
+}
+
 type roundRobinStrategy[T any] struct {
 	counter uint64
 }
@@ -43,17 +90,43 @@ func (s *roundRobinStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 	return vs[int(n%uint64(len(vs)))]
 }
 
+// weightScale scales the fractional weights to integers.
+const weightScale = 1000
+
+// fenwickThreshold is the number of the objects above which
+// the weighted strategies keep the weights in a FenwickWeighted across the selections.
+const fenwickThreshold = 64
+
 type randomStrategy[T any] struct {
-	rw *RandomWeighted[T]
-	mu sync.Mutex
+	options strategyOptions
+	rw      *RandomWeighted[T]
+	fw      *FenwickWeighted[T]
+	ids     []string
+	// members, mins and pos are the identities, the minimum shares
+	// and the indexes in rw (-1 if excluded) of the objects added to rw.
+	members []string
+	mins    []float64
+	pos     []int
+	weights []int
+	r       *rand.Rand
+	mu      sync.Mutex
 }
 
 // RandomStrategy is a strategy for node selector.
-// The node will be selected randomly.
-func RandomStrategy[T any]() selector.Strategy[T] {
-	return &randomStrategy[T]{
-		rw: NewRandomWeighted[T](),
+// The node will be selected randomly by its weight,
+// it is guaranteed a minimum share of the selections with the minShare metadata label or StrategyMinShareOption.
+// The node with an explicit weight 0 is drained and never selected, unless all the nodes have weight 0,
+// then the node is selected uniformly at random.
+func RandomStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
+	options := newStrategyOptions(opts)
+	s := &randomStrategy[T]{
+		options: options,
+		rw:      NewRandomWeighted[T](),
+		fw:      NewFenwickWeighted[T](),
+		r:       options.rand,
 	}
+	s.rw.r, s.fw.r = options.rand, options.rand
+	return s
 }
 
 func (s *randomStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
@@ -64,19 +137,126 @@ func (s *randomStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.weights = slices.Grow(s.weights[:0], len(vs))[:len(vs)]
+	weights := s.weights
+	for i := range vs {
+		weight := float64(s.options.drainableWeight(vs[i])) * s.options.recoveryFactor(vs[i])
+		weights[i] = int(weight * weightScale)
+	}
+
+	// the large sets without the minimum shares are sampled by the Fenwick tree,
+	// which is updated in place if the set is unchanged.
+	if len(vs) > fenwickThreshold && !s.hasMinShare(vs) {
+		return s.applyFenwick(vs, weights)
+	}
+
+	// the items are updated in place if the set is unchanged, otherwise rebuilt.
+	if !s.sync(vs, weights) {
+		s.rebuild(vs, weights)
+	}
+
+	if s.rw.sum <= 0 {
+		return vs[s.r.Intn(len(vs))]
+	}
+	return s.rw.Next()
+}
+
+// hasMinShare reports whether any object of vs is guaranteed a minimum share.
+func (s *randomStrategy[T]) hasMinShare(vs []T) bool {
+	if s.options.minShare > 0 {
+		return true
+	}
+	for i := range vs {
+		if minShareOf(vs[i]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sync updates the weights of the items in rw in place,
+// it reports false if the set of the objects, or the exclusion or minimum share of any object changes.
+func (s *randomStrategy[T]) sync(vs []T, weights []int) bool {
+	if len(s.members) != len(vs) {
+		return false
+	}
+	for i := range vs {
+		if s.members[i] != identity(vs[i]) || s.mins[i] != minShareOf(vs[i]) || (weights[i] > 0) != (s.pos[i] >= 0) {
+			return false
+		}
+	}
+
+	for i := range vs {
+		if p := s.pos[i]; p >= 0 {
+			s.rw.items[p].item = vs[i]
+			s.rw.update(p, weights[i])
+		}
+	}
+	return true
+}
+
+func (s *randomStrategy[T]) rebuild(vs []T, weights []int) {
 	s.rw.Reset()
+	s.rw.SetMinShare(s.options.minShare)
+	s.members = s.members[:0]
+	s.mins = s.mins[:0]
+	s.pos = s.pos[:0]
 	for i := range vs {
-		weight := 0
-		if md, _ := any(vs[i]).(metadata.Metadatable); md != nil {
-			weight = mdutil.GetInt(md.Metadata(), labelWeight)
+		share := minShareOf(vs[i])
+		s.members = append(s.members, identity(vs[i]))
+		s.mins = append(s.mins, share)
+		if weights[i] > 0 {
+			s.pos = append(s.pos, len(s.rw.items))
+			s.rw.AddWithMinShare(vs[i], weights[i], share)
+		} else {
+			s.pos = append(s.pos, -1)
+		}
+	}
+}
+
+func (s *randomStrategy[T]) applyFenwick(vs []T, weights []int) T {
+	same := len(s.ids) == len(vs)
+	for i := 0; same && i < len(vs); i++ {
+		same = s.ids[i] == identity(vs[i])
+	}
+
+	if same {
+		for i, w := range weights {
+			s.fw.items[i] = vs[i]
+			s.fw.Update(i, w)
 		}
-		if weight <= 0 {
-			weight = 1
+	} else {
+		s.fw.Reset()
+		for i := range vs {
+			s.fw.Add(vs[i], weights[i])
 		}
-		s.rw.Add(vs[i], weight)
+		s.ids = identities(vs)
 	}
 
-	return s.rw.Next()
+	if s.fw.sum <= 0 {
+		return vs[s.r.Intn(len(vs))]
+	}
+	return s.fw.Next()
+}
+
+// minShareOf returns the minimum share of v from its metadata.
+func minShareOf(v any) float64 {
+	if md, _ := v.(metadata.Metadatable); md != nil {
+		return mdutil.GetFloat(md.Metadata(), labelMinShare)
+	}
+	return 0
+}
+
+// weightOf returns the weight of v from its metadata, defaults to 1.
+func weightOf(v any) int {
+	weight := 0
+	if md, _ := v.(metadata.Metadatable); md != nil {
+		weight = mdutil.GetInt(md.Metadata(), labelWeight)
+	}
+	if weight <= 0 {
+		weight = 1
+	}
+	return weight
 }
 
 type fifoStrategy[T any] struct{}
@@ -96,14 +276,189 @@ func (s *fifoStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 	return vs[0]
 }
 
+// PassCounter reports the number of consecutive passing health checks of an object,
+// ok is false if the object has not been checked yet.
+type PassCounter interface {
+	ConsecutivePasses(v any) (n int, ok bool)
+}
+
+type strategyOptions struct {
+	hashKey        HashKeyFunc
+	loadFactor     float64
+	passCounter    PassCounter
+	recoveryPasses int
+	recoveryCurve  func(float64) float64
+	minShare       float64
+	weights        WeightProvider
+	tieBreak       TieBreakMode
+	rand           *rand.Rand
+}
+
+type StrategyOption func(*strategyOptions)
+
+// StrategyHashKeyOption sets the extractor of the hash key for the hash based strategies,
+// defaults to the hash source in context.
+func StrategyHashKeyOption(fn HashKeyFunc) StrategyOption {
+	return func(opts *strategyOptions) {
+		opts.hashKey = fn
+	}
+}
+
+// StrategyBoundedLoadOption enables the bounded-load spillover for the hash based strategies.
+// An object is overloaded if its active connections reach factor times the average load,
+// the overflow is then spilled to the next object which is not overloaded.
+// A factor <= 1 disables the spillover.
+func StrategyBoundedLoadOption(factor float64) StrategyOption {
+	return func(opts *strategyOptions) {
+		opts.loadFactor = factor
+	}
+}
+
+// StrategyRecoveryOption gates the weight of a recovered object by its consecutive passing health checks,
+// the effective weight is weight * curve(min(1, passes/n)), a nil curve is linear.
+// A n <= 0 disables the gating, so the weight is recovered instantly.
+// It is used by the weighted strategies (RandomStrategy, ErrorRateStrategy).
+func StrategyRecoveryOption(pc PassCounter, n int, curve func(float64) float64) StrategyOption {
+	return func(opts *strategyOptions) {
+		opts.passCounter = pc
+		opts.recoveryPasses = n
+		opts.recoveryCurve = curve
+	}
+}
+
+// StrategyMinShareOption sets the minimum share of the selections for every object of the weighted strategies,
+// the share can be set per object by the minShare metadata label.
+func StrategyMinShareOption(share float64) StrategyOption {
+	return func(opts *strategyOptions) {
+		opts.minShare = share
+	}
+}
+
+// StrategyRandOption sets the random source of the strategy, for example a seeded one for the deterministic tests,
+// it defaults to a source seeded by the current time. The source is guarded by the lock of the strategy.
+func StrategyRandOption(r *rand.Rand) StrategyOption {
+	return func(opts *strategyOptions) {
+		opts.rand = r
+	}
+}
+
+// StrategyWeightProviderOption sets the provider of the weights for the weighted strategies,
+// the weight from metadata is used if the provider has no weight for an object.
+func StrategyWeightProviderOption(p WeightProvider) StrategyOption {
+	return func(opts *strategyOptions) {
+		opts.weights = p
+	}
+}
+
+// StrategyTieBreakOption sets how the ties are broken by LeastConnStrategy and LeastLatencyStrategy,
+// defaults to TieBreakRandom.
+func StrategyTieBreakOption(mode TieBreakMode) StrategyOption {
+	return func(opts *strategyOptions) {
+		opts.tieBreak = mode
+	}
+}
+
+// weight returns the weight of v from the weight provider or its metadata.
+func (opts *strategyOptions) weight(v any) int {
+	if opts.weights != nil {
+		if weight, ok := opts.weights.Weight(v); ok && weight > 0 {
+			return weight
+		}
+	}
+	return weightOf(v)
+}
+
+// drainableWeight is the weight of v like weight,
+// except that it is 0 if the weight of v is explicitly set to 0 by the weight provider or the weight label.
+func (opts *strategyOptions) drainableWeight(v any) int {
+	if opts.weights != nil {
+		if weight, ok := opts.weights.Weight(v); ok && weight >= 0 {
+			return weight
+		}
+	}
+	if md, _ := v.(metadata.Metadatable); md != nil && md.Metadata() != nil && md.Metadata().IsExists(labelWeight) {
+		if validInt(md.Metadata().Get(labelWeight)) && mdutil.GetInt(md.Metadata(), labelWeight) == 0 {
+			return 0
+		}
+	}
+	return weightOf(v)
+}
+
+// recoveryFactor returns the scale factor of the weight of v in range [0, 1].
+func (opts *strategyOptions) recoveryFactor(v any) float64 {
+	if opts.passCounter == nil || opts.recoveryPasses <= 0 {
+		return 1
+	}
+
+	passes, ok := opts.passCounter.ConsecutivePasses(v)
+	if !ok {
+		return 1
+	}
+	x := math.Min(1, float64(passes)/float64(opts.recoveryPasses))
+	if opts.recoveryCurve != nil {
+		x = opts.recoveryCurve(x)
+	}
+	return math.Max(0, math.Min(1, x))
+}
+
+func newStrategyOptions(opts []StrategyOption) strategyOptions {
+	var options strategyOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.hashKey == nil {
+		options.hashKey = sourceHashKey
+	}
+	if options.rand == nil {
+		options.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return options
+}
+
+// boundedLoadIndex returns the index of the first object starting from start
+// whose active connections are below the bounded load of the objects.
+// If all objects are overloaded, start is returned.
+func boundedLoadIndex[T any](vs []T, start int, factor float64) int {
+	if factor <= 1 || len(vs) <= 1 {
+		return start
+	}
+
+	conns := make([]int64, len(vs))
+	var total int64
+	for i, v := range vs {
+		if c, ok := any(v).(Connectable); ok {
+			conns[i] = c.ActiveConns()
+			total += conns[i]
+		}
+	}
+
+	limit := int64(math.Ceil(factor * float64(total+1) / float64(len(vs))))
+	for i := 0; i < len(vs); i++ {
+		idx := (start + i) % len(vs)
+		if conns[idx] < limit {
+			return idx
+		}
+	}
+	return start
+}
+
 type hashStrategy[T any] struct {
-	r  *rand.Rand
-	mu sync.Mutex
+	options strategyOptions
+	r       *rand.Rand
+	mu      sync.Mutex
 }
 
-func HashStrategy[T any]() selector.Strategy[T] {
+// HashStrategy is a strategy for node selector.
+// The node will be selected by the hash of the key extracted by StrategyHashKeyOption (the hash source in context by default),
+// and randomly if the key is not available.
+// With StrategyBoundedLoadOption the load of a hot key is spilled over to the next nodes.
+func HashStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
+	options := newStrategyOptions(opts)
 	return &hashStrategy[T]{
-		r: rand.New(rand.NewSource(time.Now().UnixNano())),
+		options: options,
+		r:       options.rand,
 	}
 }
 
@@ -111,10 +466,10 @@ func (s *hashStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 	if len(vs) == 0 {
 		return
 	}
-	if h := xctx.HashFromContext(ctx); h != nil {
-		value := uint64(crc32.ChecksumIEEE([]byte(h.Source)))
-		logger.Default().Tracef("hash %s %d", h.Source, value)
-		return vs[value%uint64(len(vs))]
+	if key, ok := s.options.hashKey(ctx); ok {
+		value := uint64(crc32.ChecksumIEEE([]byte(key)))
+		logger.Default().Tracef("hash %s %d", key, value)
+		return vs[boundedLoadIndex(vs, int(value%uint64(len(vs))), s.options.loadFactor)]
 	}
 
 	s.mu.Lock()
@@ -123,14 +478,99 @@ func (s *hashStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 	return vs[s.r.Intn(len(vs))]
 }
 
+// HashByDestinationStrategy is a strategy for node selector for the object based sharding.
+// The node will be selected by the hash of the destination key in context (see xctx.Hash),
+// so the same object is always routed to the same node regardless of the client,
+// and randomly if the destination key is absent.
+func HashByDestinationStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
+	return HashStrategy[T](append([]StrategyOption{StrategyHashKeyOption(destinationHashKey)}, opts...)...)
+}
+
+// TieBreakMode is the way how the ties of the least-conn and least-latency strategies are broken.
+type TieBreakMode string
+
+const (
+	// TieBreakRandom selects one of the ties randomly.
+	TieBreakRandom TieBreakMode = "random"
+	// TieBreakStable selects the tie with the least identity, so the selection is reproducible.
+	TieBreakStable TieBreakMode = "stable"
+	// TieBreakRoundRobin rotates over the ties in their order.
+	TieBreakRoundRobin TieBreakMode = "round"
+)
+
+// tieBreaker breaks the ties of a selection, it is guarded by the lock of the strategy.
+type tieBreaker[T any] struct {
+	mode    TieBreakMode
+	r       *rand.Rand
+	counter uint64
+	v       T
+	id      string
+	n       int
+	ties    []T
+}
+
+func newTieBreaker[T any](options strategyOptions) tieBreaker[T] {
+	return tieBreaker[T]{
+		mode: options.tieBreak,
+		r:    options.rand,
+	}
+}
+
+// first starts a new set of the ties by v.
+func (t *tieBreaker[T]) first(v T) {
+	t.v, t.n = v, 1
+	switch t.mode {
+	case TieBreakStable:
+		t.id = identity(v)
+	case TieBreakRoundRobin:
+		t.ties = append(t.ties[:0], v)
+	}
+}
+
+// add adds v to the current set of the ties.
+func (t *tieBreaker[T]) add(v T) {
+	t.n++
+	switch t.mode {
+	case TieBreakStable:
+		if id := identity(v); id < t.id {
+			t.v, t.id = v, id
+		}
+	case TieBreakRoundRobin:
+		t.ties = append(t.ties, v)
+	default:
+		// reservoir sampling, so each of the ties is selected with equal probability.
+		if t.r.Intn(t.n) == 0 {
+			t.v = v
+		}
+	}
+}
+
+// result returns the selected tie and resets the breaker.
+func (t *tieBreaker[T]) result() (v T) {
+	v = t.v
+	if t.mode == TieBreakRoundRobin && len(t.ties) > 1 {
+		v = t.ties[t.counter%uint64(len(t.ties))]
+		t.counter++
+	}
+
+	var zero T
+	t.v, t.id, t.n = zero, "", 0
+	clear(t.ties)
+	t.ties = t.ties[:0]
+	return
+}
+
 type leastConnStrategy[T any] struct {
-	r  *rand.Rand
+	tb tieBreaker[T]
 	mu sync.Mutex
 }
 
-func LeastConnStrategy[T any]() selector.Strategy[T] {
+// LeastConnStrategy is a strategy for node selector.
+// The node with the least active connections will be selected,
+// and the ties are broken by the mode of StrategyTieBreakOption.
+func LeastConnStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
 	return &leastConnStrategy[T]{
-		r: rand.New(rand.NewSource(time.Now().UnixNano())),
+		tb: newTieBreaker[T](newStrategyOptions(opts)),
 	}
 }
 
@@ -139,8 +579,10 @@ func (s *leastConnStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 		return
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	var minConns int64 = math.MaxInt64
-	var candidates []T
 
 	for _, item := range vs {
 		var conns int64
@@ -150,29 +592,139 @@ func (s *leastConnStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 
 		if conns < minConns {
 			minConns = conns
-			candidates = []T{item}
+			s.tb.first(item)
 		} else if conns == minConns {
-			candidates = append(candidates, item)
+			s.tb.add(item)
 		}
 	}
+	return s.tb.result()
+}
+
+type p2cStrategy[T any] struct {
+	r  *rand.Rand
+	mu sync.Mutex
+}
+
+// P2CStrategy is a strategy for node selector by the power of two choices.
+// Two distinct nodes are sampled randomly and the one with the less load is selected,
+// the load is the active connections per weight, and the ties are broken randomly.
+// It is O(1) and avoids the herd effect of LeastConnStrategy on the single least loaded node.
+// The nodes not implementing Connectable have no load.
+func P2CStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
+	return &p2cStrategy[T]{
+		r: newStrategyOptions(opts).rand,
+	}
+}
 
-	if len(candidates) == 1 {
-		return candidates[0]
+func (s *p2cStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+	if len(vs) == 1 {
+		return vs[0]
 	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	return candidates[s.r.Intn(len(candidates))]
+	i := s.r.Intn(len(vs))
+	j := s.r.Intn(len(vs) - 1)
+	if j >= i {
+		j++
+	}
+	tie := s.r.Intn(2) == 0
+	s.mu.Unlock()
+
+	a, b := loadOf(vs[i]), loadOf(vs[j])
+	if a < b || (a == b && tie) {
+		return vs[i]
+	}
+	return vs[j]
 }
 
-type leastLatencyStrategy[T any] struct {
+type p2cLatencyStrategy[T any] struct {
 	r  *rand.Rand
 	mu sync.Mutex
 }
 
-func LeastLatencyStrategy[T any]() selector.Strategy[T] {
+// P2CLatencyStrategy is a strategy for node selector by the power of two choices with latency awareness.
+// Two distinct nodes are sampled randomly and the one with the lower latency is selected,
+// it avoids the O(n) scan of LeastLatencyStrategy and the oscillation of all the traffic to the single fastest node.
+//
+// The latency is known if the node implements LatencyStater with a positive latency, and a known latency is lower than an unknown one.
+// If both latencies are equal or unknown, the one with less active connections is selected (see Connectable),
+// and the remaining ties are broken randomly.
+func P2CLatencyStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
+	return &p2cLatencyStrategy[T]{
+		r: newStrategyOptions(opts).rand,
+	}
+}
+
+func (s *p2cLatencyStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+	if len(vs) == 1 {
+		return vs[0]
+	}
+
+	s.mu.Lock()
+	i := s.r.Intn(len(vs))
+	j := s.r.Intn(len(vs) - 1)
+	if j >= i {
+		j++
+	}
+	tie := s.r.Intn(2) == 0
+	s.mu.Unlock()
+
+	if a, b := latencyOf(vs[i]), latencyOf(vs[j]); a != b {
+		if a < b {
+			return vs[i]
+		}
+		return vs[j]
+	}
+	if a, b := connsOf(vs[i]), connsOf(vs[j]); a < b || (a == b && tie) {
+		return vs[i]
+	}
+	return vs[j]
+}
+
+// latencyOf returns the latency of v, or math.MaxInt64 if the latency is unknown.
+func latencyOf(v any) time.Duration {
+	if ls, ok := v.(LatencyStater); ok {
+		if latency := ls.Latency(); latency > 0 {
+			return latency
+		}
+	}
+	return math.MaxInt64
+}
+
+// connsOf returns the active connections of v, or 0 if v does not implement Connectable.
+func connsOf(v any) int64 {
+	if c, ok := v.(Connectable); ok {
+		return c.ActiveConns()
+	}
+	return 0
+}
+
+// loadOf returns the active connections of v per weight.
+func loadOf(v any) float64 {
+	c, ok := v.(Connectable)
+	if !ok {
+		return 0
+	}
+	return float64(c.ActiveConns()) / float64(weightOf(v))
+}
+
+type leastLatencyStrategy[T any] struct {
+	tb tieBreaker[T]
+	mu sync.Mutex
+}
+
+// LeastLatencyStrategy is a strategy for node selector.
+// The node with the least latency will be selected, the nodes without latency data are selected last,
+// and the ties are broken by the mode of StrategyTieBreakOption.
+func LeastLatencyStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
 	return &leastLatencyStrategy[T]{
-		r: rand.New(rand.NewSource(time.Now().UnixNano())),
+		tb: newTieBreaker[T](newStrategyOptions(opts)),
 	}
 }
 
@@ -181,8 +733,10 @@ func (s *leastLatencyStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 		return
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	var minLatency time.Duration = math.MaxInt64
-	var candidates []T
 
 	for _, item := range vs {
 		var latency time.Duration = math.MaxInt64
@@ -196,17 +750,407 @@ func (s *leastLatencyStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
 
 		if latency < minLatency {
 			minLatency = latency
-			candidates = []T{item}
+			s.tb.first(item)
 		} else if latency == minLatency {
+			s.tb.add(item)
+		}
+	}
+	return s.tb.result()
+}
+
+// unknownLatency is the latency of the objects without latency data for WeightedLeastLatencyStrategy.
+const unknownLatency = time.Minute
+
+type weightedLeastLatencyStrategy[T any] struct {
+	r  *rand.Rand
+	mu sync.Mutex
+}
+
+// WeightedLeastLatencyStrategy is a strategy for node selector.
+// The node with the minimum latency per weight will be selected, and randomly among the ties,
+// so the nodes of higher capacity tolerate proportionally higher latency.
+// The nodes without latency data are treated as having a latency of one minute.
+func WeightedLeastLatencyStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
+	return &weightedLeastLatencyStrategy[T]{
+		r: newStrategyOptions(opts).rand,
+	}
+}
+
+func (s *weightedLeastLatencyStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	minScore := math.Inf(1)
+	var ties int
+
+	for _, item := range vs {
+		latency := unknownLatency
+		if ls, ok := any(item).(LatencyStater); ok {
+			if l := ls.Latency(); l > 0 {
+				latency = l
+			}
+		}
+		score := float64(latency) / float64(weightOf(item))
+
+		if score < minScore {
+			minScore = score
+			v, ties = item, 1
+		} else if score == minScore {
+			if ties++; s.r.Intn(ties) == 0 {
+				v = item
+			}
+		}
+	}
+	return
+}
+
+// Confirmer is implemented by the strategies which track
+// the selected objects until the caller confirms the result of the selection,
+// the selector created by NewSelector implements Confirmer and forwards it to its strategy.
+type Confirmer[T any] interface {
+	// Confirm is called when the connection to v is established,
+	// from now on the active connections of v take over.
+	Confirm(v T)
+	// Cancel is called when the connection to v is failed.
+	Cancel(v T)
+}
+
+// pendingTimeout is the lifetime of a pending selection which is never confirmed or canceled.
+const pendingTimeout = 30 * time.Second
+
+type pendingLeastConnStrategy[T any] struct {
+	stats nodeStats
+	// pending is the start times of the pending selections of each object, the oldest first.
+	pending map[string][]time.Time
+	r       *rand.Rand
+	mu      sync.Mutex
+}
+
+// PendingLeastConnStrategy is a least-conn strategy for node selector,
+// the objects that have been selected but are not connected yet are counted as pending connections,
+// so that bursty selections will not pick the same object before its active connections are increased.
+//
+// Each selected object should be released by calling Confirm once the connection is established
+// (from then on the active connections of the object take over), or Cancel if the connection fails,
+// the selector created by NewSelector forwards the Confirmer interface to its strategy.
+// A pending selection which is not released expires after 30 seconds,
+// and the objects which leave the candidates are forgotten once they have no pending selection.
+func PendingLeastConnStrategy[T any]() selector.Strategy[T] {
+	return &pendingLeastConnStrategy[T]{
+		stats:   make(nodeStats),
+		pending: make(map[string][]time.Time),
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *pendingLeastConnStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(vs, now)
+
+	var minConns int64 = math.MaxInt64
+	var candidates []T
+
+	for _, item := range vs {
+		id := identity(item)
+		st := s.stats.get(id)
+		if c, ok := any(item).(Connectable); ok {
+			st.ActiveConns = c.ActiveConns()
+		}
+		conns := st.ActiveConns + int64(len(s.pending[id]))
+
+		if conns < minConns {
+			minConns = conns
+			candidates = []T{item}
+		} else if conns == minConns {
 			candidates = append(candidates, item)
 		}
 	}
 
-	if len(candidates) == 1 {
-		return candidates[0]
+	v = candidates[0]
+	if len(candidates) > 1 {
+		v = candidates[s.r.Intn(len(candidates))]
 	}
+	id := identity(v)
+	s.pending[id] = append(s.pending[id], now)
+	s.stats.get(id).Selections++
+
+	return
+}
+
+// prune expires the pending selections and forgets the objects absent from vs without pending selection.
+func (s *pendingLeastConnStrategy[T]) prune(vs []T, now time.Time) {
+	for id, l := range s.pending {
+		n := 0
+		for n < len(l) && now.Sub(l[n]) > pendingTimeout {
+			n++
+		}
+		if n == len(l) {
+			delete(s.pending, id)
+		} else if n > 0 {
+			s.pending[id] = l[n:]
+		}
+	}
+
+	if len(s.stats) <= len(vs) {
+		return
+	}
+	ids := make(map[string]struct{}, len(vs))
+	for _, v := range vs {
+		ids[identity(v)] = struct{}{}
+	}
+	for id := range s.stats {
+		if _, ok := ids[id]; !ok && len(s.pending[id]) == 0 {
+			delete(s.stats, id)
+		}
+	}
+}
+
+// Confirm implements Confirmer interface, it releases the oldest pending selection of v.
+func (s *pendingLeastConnStrategy[T]) Confirm(v T) {
+	s.release(v)
+}
+
+// Cancel implements Confirmer interface, it releases the oldest pending selection of v.
+func (s *pendingLeastConnStrategy[T]) Cancel(v T) {
+	s.release(v)
+}
+
+func (s *pendingLeastConnStrategy[T]) release(v T) {
+	id := identity(v)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return candidates[s.r.Intn(len(candidates))]
+
+	switch l := s.pending[id]; len(l) {
+	case 0:
+	case 1:
+		delete(s.pending, id)
+	default:
+		s.pending[id] = l[1:]
+	}
+}
+
+// pendings returns the number of the unexpired pending selections of the object id.
+func (s *pendingLeastConnStrategy[T]) pendings(id string, now time.Time) int64 {
+	var n int64
+	for _, t := range s.pending[id] {
+		if now.Sub(t) <= pendingTimeout {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *pendingLeastConnStrategy[T]) Stats() map[string]NodeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats.snapshot()
+	now := time.Now()
+	for id, st := range stats {
+		st.Pending = s.pendings(id, now)
+		stats[id] = st
+	}
+	return stats
+}
+
+type timeBucketStrategy[T any] struct {
+	bucket time.Duration
+}
+
+// TimeBucketStrategy is a strategy for node selector.
+// The time is divided into buckets of the given duration,
+// and the node at index (now/bucket) % len(nodes) is selected during each bucket,
+// so the independent instances with synchronized clocks select the same node at the same time.
+//
+// At the bucket boundaries the new selections rotate to the next node,
+// while the ongoing connections stay on the previous one until they are closed.
+func TimeBucketStrategy[T any](bucket time.Duration) selector.Strategy[T] {
+	if bucket <= 0 {
+		bucket = time.Minute
+	}
+	return &timeBucketStrategy[T]{
+		bucket: bucket,
+	}
+}
+
+func (s *timeBucketStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	n := uint64(time.Now().UnixNano() / int64(s.bucket))
+	return vs[int(n%uint64(len(vs)))]
+}
+
+// errorRateAlpha is the smoothing factor of the error rate.
+const errorRateAlpha = 0.1
+
+// minErrorRateShare is the minimum share of the weight of a node by its error rate,
+// it keeps the scaled weight of the node at least 1.
+const minErrorRateShare = 1.0 / weightScale
+
+type errorRateStrategy[T any] struct {
+	options strategyOptions
+	stats   nodeStats
+	rw      *RandomWeighted[T]
+	r       *rand.Rand
+	mu      sync.Mutex
+}
+
+// ErrorRateStrategy is a strategy for node selector.
+// The node will be selected randomly with the weight scaled by (1 - error rate) (at least 1/weightScale),
+// the error rate is the moving average of the outcomes reported through the Reporter interface.
+// The nodes without any report have the full weight.
+func ErrorRateStrategy[T any](opts ...StrategyOption) selector.Strategy[T] {
+	options := newStrategyOptions(opts)
+	s := &errorRateStrategy[T]{
+		options: options,
+		stats:   make(nodeStats),
+		rw:      NewRandomWeighted[T](),
+		r:       options.rand,
+	}
+	s.rw.r = options.rand
+	return s
+}
+
+func (s *errorRateStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rw.Reset()
+	for i := range vs {
+		// the share of the error rate is floored, so a failing node still gets the occasional traffic
+		// whose reports let its error rate decay, instead of being ejected permanently.
+		share := max(1-s.stats.get(identity(vs[i])).ErrorRate, minErrorRateShare)
+		weight := float64(s.options.weight(vs[i])) * share * s.options.recoveryFactor(vs[i])
+		if w := int(weight * weightScale); w > 0 {
+			s.rw.Add(vs[i], w)
+		}
+	}
+
+	if s.rw.sum <= 0 {
+		v = vs[s.r.Intn(len(vs))]
+	} else {
+		v = s.rw.Next()
+	}
+	s.stats.get(identity(v)).Selections++
+
+	return
+}
+
+func (s *errorRateStrategy[T]) Report(v T, outcome Outcome, latency time.Duration) {
+	var x float64
+	if outcome != OutcomeSuccess {
+		x = 1
+	}
+
+	id := identity(v)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stats.get(id)
+	st.ErrorRate = st.ErrorRate*(1-errorRateAlpha) + x*errorRateAlpha
+}
+
+func (s *errorRateStrategy[T]) Stats() map[string]NodeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats.snapshot()
+}
+
+// ExportState implements StateTransferer interface.
+func (s *errorRateStrategy[T]) ExportState() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats.export("errorRate")
+}
+
+// ImportState implements StateTransferer interface.
+func (s *errorRateStrategy[T]) ImportState(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats.load("errorRate", data)
+}
+
+type hashRoundRobinStrategy[T any] struct {
+	counter uint64
+}
+
+// HashRoundRobinStrategy is a strategy for node selector.
+// The node at index (hash(source) + n) % len(nodes) will be selected,
+// where source is the hash source in context and n is the count of selections,
+// so the selections of the same source start from a stable position and rotate over the nodes on retries.
+// It falls back to round-robin if no hash source is present in context.
+func HashRoundRobinStrategy[T any]() selector.Strategy[T] {
+	return &hashRoundRobinStrategy[T]{}
+}
+
+func (s *hashRoundRobinStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	n := atomic.AddUint64(&s.counter, 1) - 1
+	if h := xctx.HashFromContext(ctx); h != nil {
+		n += uint64(crc32.ChecksumIEEE([]byte(h.Source)))
+	}
+	return vs[int(n%uint64(len(vs)))]
+}
+
+// StrategyPicker picks the strategy for the selection of the request in ctx,
+// a nil strategy means the default one.
+type StrategyPicker[T any] func(ctx context.Context) selector.Strategy[T]
+
+type compositeStrategy[T any] struct {
+	def  selector.Strategy[T]
+	pick StrategyPicker[T]
+}
+
+// CompositeStrategy is a strategy for node selector which delegates each selection
+// to the strategy picked by the context of the request, for example the hash strategy for the cacheable requests
+// and the least-conn strategy otherwise. The def strategy is used if pick is nil or returns nil.
+//
+// The strategies returned by pick should be constructed once and reused,
+// as the stateful strategies (round-robin, least-conn, etc.) keep their state across the selections.
+func CompositeStrategy[T any](def selector.Strategy[T], pick StrategyPicker[T]) selector.Strategy[T] {
+	if def == nil {
+		def = RoundRobinStrategy[T]()
+	}
+	return &compositeStrategy[T]{
+		def:  def,
+		pick: pick,
+	}
+}
+
+func (s *compositeStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	if s.pick != nil {
+		if strategy := s.pick(ctx); strategy != nil {
+			return strategy.Apply(ctx, vs...)
+		}
+	}
+	return s.def.Apply(ctx, vs...)
 }
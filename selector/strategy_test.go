@@ -0,0 +1,418 @@
+package selector
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/selector"
+	mdx "github.com/go-gost/x/metadata"
+)
+
+type testNode struct {
+	id      string
+	conns   int64
+	latency time.Duration
+	md      metadata.Metadata
+	marker  selector.Marker
+}
+
+// newTestNode creates a node with the labels and a fail marker.
+func newTestNode(id string, labels map[string]any) *testNode {
+	return &testNode{
+		id:     id,
+		md:     mdx.NewMetadata(labels),
+		marker: selector.NewFailMarker(),
+	}
+}
+
+func (n *testNode) ID() string {
+	return n.id
+}
+
+func (n *testNode) Metadata() metadata.Metadata {
+	return n.md
+}
+
+func (n *testNode) Marker() selector.Marker {
+	return n.marker
+}
+
+// markFailed resets the markers of the nodes and marks the failed ones.
+func markFailed(nodes []*testNode, failed ...*testNode) {
+	for _, node := range nodes {
+		node.marker.Reset()
+	}
+	for _, node := range failed {
+		node.marker.Mark()
+	}
+}
+
+func (n *testNode) ActiveConns() int64 {
+	return n.conns
+}
+
+func (n *testNode) Latency() time.Duration {
+	return n.latency
+}
+
+// assertUniform asserts that the selections are spread evenly over the nodes of the ids.
+func assertUniform(t *testing.T, counts map[string]int, ids []string, n int) {
+	t.Helper()
+
+	expected := 1 / float64(len(ids))
+	for _, id := range ids {
+		if got := float64(counts[id]) / float64(n); got < expected-0.02 || got > expected+0.02 {
+			t.Errorf("node %s: got share %.4f, expected %.4f", id, got, expected)
+		}
+	}
+	if total := len(counts); total != len(ids) {
+		t.Errorf("got %d selected nodes, expected %d", total, len(ids))
+	}
+}
+
+func TestLeastConnStrategyTies(t *testing.T) {
+	nodes := []*testNode{
+		{id: "a", conns: 1},
+		{id: "b", conns: 3},
+		{id: "c", conns: 1},
+		{id: "d", conns: 1},
+		{id: "e", conns: 2},
+	}
+	s := LeastConnStrategy[*testNode](StrategyRandOption(rand.New(rand.NewSource(1))))
+
+	const n = 30000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[s.Apply(context.Background(), nodes...).id]++
+	}
+	assertUniform(t, counts, []string{"a", "c", "d"}, n)
+}
+
+func TestLeastLatencyStrategyTies(t *testing.T) {
+	nodes := []*testNode{
+		{id: "a", latency: 2 * time.Millisecond},
+		{id: "b", latency: time.Millisecond},
+		{id: "c"},
+		{id: "d", latency: time.Millisecond},
+	}
+	s := LeastLatencyStrategy[*testNode](StrategyRandOption(rand.New(rand.NewSource(1))))
+
+	const n = 30000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[s.Apply(context.Background(), nodes...).id]++
+	}
+	assertUniform(t, counts, []string{"b", "d"}, n)
+}
+
+func TestLeastConnStrategyTieBreak(t *testing.T) {
+	nodes := []*testNode{
+		{id: "d", conns: 1},
+		{id: "b", conns: 3},
+		{id: "c", conns: 1},
+		{id: "a", conns: 2},
+	}
+	ctx := context.Background()
+
+	s := LeastConnStrategy[*testNode](StrategyTieBreakOption(TieBreakStable))
+	for i := 0; i < 10; i++ {
+		if v := s.Apply(ctx, nodes...); v.id != "c" {
+			t.Fatalf("stable: got %s, expected c", v.id)
+		}
+	}
+
+	s = LeastConnStrategy[*testNode](StrategyTieBreakOption(TieBreakRoundRobin))
+	for i, expected := range []string{"d", "c", "d", "c"} {
+		if v := s.Apply(ctx, nodes...); v.id != expected {
+			t.Fatalf("round #%d: got %s, expected %s", i, v.id, expected)
+		}
+	}
+}
+
+func TestErrorRateStrategyRecovery(t *testing.T) {
+	a := &testNode{id: "a"}
+	b := &testNode{id: "b"}
+	nodes := []*testNode{a, b}
+	ctx := context.Background()
+
+	s := ErrorRateStrategy[*testNode](StrategyRandOption(rand.New(rand.NewSource(1))))
+	r := s.(Reporter[*testNode])
+	for i := 0; i < 1000; i++ {
+		r.Report(a, OutcomeFailure, 0)
+	}
+
+	// the failing node still gets the occasional selections, and recovers by their successful reports.
+	const n = 20000
+	count := 0
+	for i := 0; i < n; i++ {
+		v := s.Apply(ctx, nodes...)
+		r.Report(v, OutcomeSuccess, 0)
+		if v == a && i >= n-2000 {
+			count++
+		}
+	}
+	if share := float64(count) / 2000; share < 0.4 {
+		t.Errorf("got share %.4f of the recovered node, expected about 0.5", share)
+	}
+}
+
+func benchNodes(n int) []*testNode {
+	r := rand.New(rand.NewSource(1))
+	nodes := make([]*testNode, n)
+	for i := range nodes {
+		nodes[i] = &testNode{
+			id:    strconv.Itoa(i),
+			conns: r.Int63n(100),
+		}
+	}
+	return nodes
+}
+
+func BenchmarkLeastConnStrategy(b *testing.B) {
+	nodes := benchNodes(1000)
+	s := LeastConnStrategy[*testNode]()
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Apply(ctx, nodes...)
+	}
+}
+
+func BenchmarkP2CStrategy(b *testing.B) {
+	nodes := benchNodes(1000)
+	s := P2CStrategy[*testNode]()
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Apply(ctx, nodes...)
+	}
+}
+
+// newWeightedNode creates a node with the weight label, the label is absent if weight < 0.
+func newWeightedNode(id string, weight int) *testNode {
+	m := map[string]any{}
+	if weight >= 0 {
+		m[labelWeight] = weight
+	}
+	return newTestNode(id, m)
+}
+
+func TestRandomStrategyZeroWeight(t *testing.T) {
+	const n = 30000
+
+	t.Run("mixed", func(t *testing.T) {
+		for _, size := range []int{3, 2 * fenwickThreshold} {
+			nodes := []*testNode{
+				newWeightedNode("drained", 0),
+				newWeightedNode("default", -1),
+				newWeightedNode("double", 2),
+			}
+			for i := len(nodes); i < size; i++ {
+				nodes = append(nodes, newWeightedNode("drained-"+strconv.Itoa(i), 0))
+			}
+
+			s := RandomStrategy[*testNode](StrategyRandOption(rand.New(rand.NewSource(1))))
+			counts := make(map[string]int)
+			for i := 0; i < n; i++ {
+				counts[s.Apply(context.Background(), nodes...).id]++
+			}
+
+			if len(counts) != 2 {
+				t.Errorf("size %d: got selected nodes %v, expected only default and double", size, counts)
+			}
+			if got := float64(counts["double"]) / float64(counts["default"]); got < 1.9 || got > 2.1 {
+				t.Errorf("size %d: got ratio %.4f of double to default, expected 2", size, got)
+			}
+		}
+	})
+
+	t.Run("all zero", func(t *testing.T) {
+		var nodes []*testNode
+		var ids []string
+		for i := 0; i < 4; i++ {
+			id := "drained-" + strconv.Itoa(i)
+			nodes = append(nodes, newWeightedNode(id, 0))
+			ids = append(ids, id)
+		}
+
+		s := RandomStrategy[*testNode](StrategyRandOption(rand.New(rand.NewSource(1))))
+		counts := make(map[string]int)
+		for i := 0; i < n; i++ {
+			v := s.Apply(context.Background(), nodes...)
+			if v == nil {
+				t.Fatal("got nil node, expected a uniform fallback")
+			}
+			counts[v.id]++
+		}
+		assertUniform(t, counts, ids, n)
+	})
+
+	t.Run("rank", func(t *testing.T) {
+		nodes := []*testNode{
+			newWeightedNode("drained", 0),
+			newWeightedNode("default", -1),
+			newWeightedNode("double", 2),
+		}
+
+		s := RandomStrategy[*testNode](StrategyRandOption(rand.New(rand.NewSource(1))))
+		firsts := make(map[string]int)
+		for i := 0; i < n/10; i++ {
+			l := s.(Ranker[*testNode]).Rank(context.Background(), nodes...)
+			if len(l) != len(nodes) {
+				t.Fatalf("got %d ranked nodes, expected %d", len(l), len(nodes))
+			}
+			if l[len(l)-1].id != "drained" {
+				t.Fatalf("got node %s ranked last, expected the drained node", l[len(l)-1].id)
+			}
+			firsts[l[0].id]++
+		}
+		if got := float64(firsts["double"]) / float64(firsts["default"]); got < 1.8 || got > 2.2 {
+			t.Errorf("got ratio %.4f of double to default ranked first, expected 2", got)
+		}
+	})
+}
+
+func TestEWMALatencyStrategyUnknownLatency(t *testing.T) {
+	const n = 1000
+
+	tests := []struct {
+		name     string
+		nodes    []*testNode
+		expected string
+	}{
+		// the node without latency data is penalized by its in-flight requests at the mean latency.
+		{"busy", []*testNode{{id: "a", conns: 4}, {id: "b", latency: 10 * time.Millisecond}, {id: "c", latency: 30 * time.Millisecond}}, "b"},
+		{"idle", []*testNode{{id: "a"}, {id: "b", latency: 10 * time.Millisecond, conns: 4}, {id: "c", latency: 30 * time.Millisecond, conns: 4}}, "a"},
+		// without any latency data, the nodes are compared by their active connections.
+		{"no data", []*testNode{{id: "a", conns: 2}, {id: "b", conns: 1}, {id: "c", conns: 3}}, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := EWMALatencyStrategy[*testNode](0)
+			for i := 0; i < n; i++ {
+				if got := s.Apply(context.Background(), tt.nodes...); got.id != tt.expected {
+					t.Fatalf("got node %s, expected %s", got.id, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRandomStrategy selects from a stable set of weighted nodes below the Fenwick threshold,
+// the reset case is the baseline which rebuilds the weighted items by Reset and Add on each selection.
+func BenchmarkRandomStrategy(b *testing.B) {
+	var nodes []*testNode
+	for i := 0; i < fenwickThreshold/2; i++ {
+		nodes = append(nodes, newWeightedNode(strconv.Itoa(i), i%10+1))
+	}
+	ctx := context.Background()
+
+	b.Run("sync", func(b *testing.B) {
+		s := RandomStrategy[*testNode]()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.Apply(ctx, nodes...)
+		}
+	})
+
+	b.Run("reset", func(b *testing.B) {
+		options := newStrategyOptions(nil)
+		rw := NewRandomWeighted[*testNode]()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			rw.Reset()
+			rw.SetMinShare(options.minShare)
+			for _, v := range nodes {
+				weight := float64(options.drainableWeight(v)) * options.recoveryFactor(v)
+				if w := int(weight * weightScale); w > 0 {
+					rw.AddWithMinShare(v, w, minShareOf(v))
+				}
+			}
+			rw.Next()
+		}
+	})
+}
+
+func TestRankDoesNotAdvanceState(t *testing.T) {
+	nodes := []*testNode{{id: "a"}, {id: "b"}, {id: "c"}}
+
+	s := PendingLeastConnStrategy[*testNode]()
+	sel := NewSelector(s).(Ranker[*testNode])
+	for i := 0; i < 10; i++ {
+		if got := sel.Rank(context.Background(), nodes...); len(got) != len(nodes) {
+			t.Fatalf("got %d ranked nodes, expected %d", len(got), len(nodes))
+		}
+	}
+	for id, st := range s.(*pendingLeastConnStrategy[*testNode]).Stats() {
+		if st.Pending != 0 || st.Selections != 0 {
+			t.Errorf("node %s: got pending %d and selections %d after ranking", id, st.Pending, st.Selections)
+		}
+	}
+
+	// the strategies without Ranker keep the filtered order.
+	sel = NewSelector(P2CStrategy[*testNode]()).(Ranker[*testNode])
+	got := sel.Rank(context.Background(), nodes...)
+	if len(got) != len(nodes) {
+		t.Fatalf("got %d ranked nodes, expected %d", len(got), len(nodes))
+	}
+	for i := range nodes {
+		if got[i] != nodes[i] {
+			t.Errorf("rank %d: got node %s, expected %s", i, got[i].id, nodes[i].id)
+		}
+	}
+}
+
+func TestPendingLeastConnStrategyConfirm(t *testing.T) {
+	a := &testNode{id: "a"}
+	b := &testNode{id: "b"}
+	ctx := context.Background()
+
+	s := PendingLeastConnStrategy[*testNode]()
+	ps := s.(*pendingLeastConnStrategy[*testNode])
+	sel := NewSelector(s)
+	pending := func(id string) int64 {
+		return ps.Stats()[id].Pending
+	}
+
+	v := sel.Select(ctx, a, b)
+	if got := sel.Select(ctx, a, b); got == v {
+		t.Fatalf("got node %s selected twice while pending", v.id)
+	}
+
+	// the reports do not release the pending selections, so a confirmed selection is released once.
+	sel.(Confirmer[*testNode]).Confirm(a)
+	sel.(Reporter[*testNode]).Report(a, OutcomeSuccess, 0)
+	if got := pending("a"); got != 0 {
+		t.Errorf("got %d pending selections of a, expected 0", got)
+	}
+	if got := pending("b"); got != 1 {
+		t.Errorf("got %d pending selections of b, expected 1", got)
+	}
+	sel.(Confirmer[*testNode]).Cancel(a)
+	if got := pending("b"); got != 1 {
+		t.Errorf("got %d pending selections of b after releasing a, expected 1", got)
+	}
+
+	// the unreleased selections expire.
+	ps.pending["b"][0] = time.Now().Add(-2 * pendingTimeout)
+	if got := pending("b"); got != 0 {
+		t.Errorf("got %d pending selections of b after expiry, expected 0", got)
+	}
+
+	// the objects leaving the candidates are forgotten.
+	sel.Select(ctx, a)
+	sel.(Confirmer[*testNode]).Confirm(a)
+	if _, ok := ps.Stats()["b"]; ok {
+		t.Error("got the stats of b after it leaves the candidates")
+	}
+}
@@ -0,0 +1,107 @@
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+)
+
+// MetadataError reports a selector label whose value can not be parsed,
+// the label is ignored at runtime and the default value is used instead.
+type MetadataError struct {
+	Label  string
+	Value  any
+	Expect string
+}
+
+func (e *MetadataError) Error() string {
+	return fmt.Sprintf("selector: invalid value %v (%T) for label %s, expect %s", e.Value, e.Value, e.Label, e.Expect)
+}
+
+// ValidateMetadata checks the values of the selector labels in md,
+// the returned error joins a MetadataError for each unparseable label.
+// Both the tier and the priority labels are integers of opposite scales:
+// tier orders the selection by PriorityFilter, the lower first,
+// and priority scales the health check interval, the higher more frequently.
+func ValidateMetadata(md metadata.Metadata) error {
+	if md == nil {
+		return nil
+	}
+
+	var errs []error
+	check := func(label, expect string, valid func(any) bool) {
+		if !md.IsExists(label) {
+			return
+		}
+		if v := md.Get(label); !valid(v) {
+			errs = append(errs, &MetadataError{Label: label, Value: v, Expect: expect})
+		}
+	}
+
+	check(labelWeight, "integer", validInt)
+	check(labelMaxFails, "integer", validInt)
+	check(labelFailTimeout, "duration", validDuration)
+	check(labelMinShare, "number", validFloat)
+	check(labelPriority, "integer", validInt)
+	check(labelBackup, "boolean", validBool)
+	check(labelLocal, "boolean", validBool)
+	check(labelMaintenance, "boolean", validBool)
+	check(labelMaxConns, "integer", validInt)
+	check(labelDefault, "boolean", validBool)
+	check(labelDrain, "boolean", validBool)
+	check(labelTier, "integer", validInt)
+
+	return errors.Join(errs...)
+}
+
+// The validators accept the same types and formats as the metadata/util getters.
+
+func validInt(v any) bool {
+	switch vv := v.(type) {
+	case bool, int:
+		return true
+	case string:
+		_, err := strconv.Atoi(vv)
+		return err == nil
+	}
+	return false
+}
+
+func validFloat(v any) bool {
+	switch vv := v.(type) {
+	case float64, int:
+		return true
+	case string:
+		_, err := strconv.ParseFloat(vv, 64)
+		return err == nil
+	}
+	return false
+}
+
+func validBool(v any) bool {
+	switch vv := v.(type) {
+	case bool, int:
+		return true
+	case string:
+		_, err := strconv.ParseBool(vv)
+		return err == nil
+	}
+	return false
+}
+
+func validDuration(v any) bool {
+	switch vv := v.(type) {
+	case int:
+		return true
+	case string:
+		if _, err := time.ParseDuration(vv); err == nil {
+			return true
+		}
+		_, err := strconv.Atoi(vv)
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package selector
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/selector"
+	mdutil "github.com/go-gost/x/metadata/util"
+)
+
+type preferVersionStrategy[T any] struct {
+	target     string
+	preference float64
+	rw         *RandomWeighted[T]
+	r          *rand.Rand
+	mu         sync.Mutex
+}
+
+// PreferVersionStrategy is a strategy for node selector for the progressive rollouts.
+// The node will be selected randomly by its weight, and the weight of the nodes
+// running the target version (the version label) is multiplied by preference,
+// so the upgraded nodes receive more traffic while the others still receive some.
+//
+// A node runs the target version if its version equals targetVersion,
+// or both of them are semantic versions (such as v1.2.3) and the version of the node is not older than the target.
+func PreferVersionStrategy[T any](targetVersion string, preference float64) selector.Strategy[T] {
+	if preference <= 0 {
+		preference = 1
+	}
+	return &preferVersionStrategy[T]{
+		target:     targetVersion,
+		preference: preference,
+		rw:         NewRandomWeighted[T](),
+		r:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *preferVersionStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rw.Reset()
+	for i := range vs {
+		weight := float64(weightOf(vs[i]))
+		if s.target != "" && matchVersion(versionOf(vs[i]), s.target) {
+			weight *= s.preference
+		}
+		if w := int(weight * weightScale); w > 0 {
+			s.rw.Add(vs[i], w)
+		}
+	}
+
+	if s.rw.sum <= 0 {
+		return vs[s.r.Intn(len(vs))]
+	}
+	return s.rw.Next()
+}
+
+func versionOf(v any) string {
+	if md, _ := v.(metadata.Metadatable); md != nil {
+		return mdutil.GetString(md.Metadata(), labelVersion)
+	}
+	return ""
+}
+
+// matchVersion reports whether version is the target or newer by the order of semantic versions.
+func matchVersion(version, target string) bool {
+	if version == "" {
+		return false
+	}
+	if version == target {
+		return true
+	}
+
+	a, ok := parseSemver(version)
+	if !ok {
+		return false
+	}
+	b, ok := parseSemver(target)
+	if !ok {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return true
+}
+
+// parseSemver parses the major, minor and patch numbers of a semantic version with optional v prefix,
+// the pre-release and build parts are ignored.
+func parseSemver(s string) (v [3]int, ok bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return
+		}
+		v[i] = n
+	}
+	return v, true
+}
@@ -6,13 +6,19 @@ import (
 )
 
 type randomWeightedItem[T any] struct {
-	item   T
-	weight int
+	item     T
+	weight   int
+	minShare float64
 }
 
 type RandomWeighted[T any] struct {
-	items []*randomWeightedItem[T]
-	sum   int
+	items    []*randomWeightedItem[T]
+	sum      int
+	minShare float64
+	shares   []float64
+	// prob and alias are the alias table of the items built by Vose's alias method.
+	prob  []float64
+	alias []int
 	r     *rand.Rand
 }
 
@@ -23,11 +29,28 @@ func NewRandomWeighted[T any]() *RandomWeighted[T] {
 }
 
 func (rw *RandomWeighted[T]) Add(item T, weight int) {
-	ri := &randomWeightedItem[T]{item: item, weight: weight}
+	rw.AddWithMinShare(item, weight, 0)
+}
+
+// AddWithMinShare adds an item with its minimum share of the selections in range [0, 1],
+// it takes precedence over the share set by SetMinShare if it is greater than 0.
+func (rw *RandomWeighted[T]) AddWithMinShare(item T, weight int, minShare float64) {
+	ri := &randomWeightedItem[T]{item: item, weight: weight, minShare: minShare}
 	rw.items = append(rw.items, ri)
 	rw.sum += weight
+	rw.invalidate()
+}
+
+// SetMinShare sets the minimum share of the selections for every item,
+// the probability of each item is floored at the share and the rest is renormalized by weights,
+// so that the items with tiny weights will not be starved.
+func (rw *RandomWeighted[T]) SetMinShare(share float64) {
+	rw.minShare = share
+	rw.invalidate()
 }
 
+// Next selects an item randomly by the weights and the minimum shares in O(1),
+// the alias table is rebuilt in O(n) on the first selection after the items change.
 func (rw *RandomWeighted[T]) Next() (v T) {
 	if len(rw.items) == 0 {
 		return
@@ -35,18 +58,282 @@ func (rw *RandomWeighted[T]) Next() (v T) {
 	if rw.sum <= 0 {
 		return
 	}
-	weight := rw.r.Intn(rw.sum) + 1
-	for _, item := range rw.items {
-		weight -= item.weight
-		if weight <= 0 {
-			return item.item
+
+	if rw.prob == nil {
+		rw.buildAlias()
+	}
+
+	i := rw.r.Intn(len(rw.items))
+	if rw.r.Float64() < rw.prob[i] {
+		return rw.items[i].item
+	}
+	return rw.items[rw.alias[i]].item
+}
+
+// buildAlias builds the alias table of the probabilities of the items by Vose's alias method.
+func (rw *RandomWeighted[T]) buildAlias() {
+	n := len(rw.items)
+	scaled := make([]float64, n)
+	if shares := rw.floorShares(); shares != nil {
+		for i, share := range shares {
+			scaled[i] = share * float64(n)
+		}
+	} else {
+		for i, item := range rw.items {
+			scaled[i] = float64(item.weight) * float64(n) / float64(rw.sum)
 		}
 	}
 
-	return rw.items[len(rw.items)-1].item
+	rw.prob = make([]float64, n)
+	rw.alias = make([]int, n)
+
+	var small, large []int
+	for i, p := range scaled {
+		if p < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		l, g := small[len(small)-1], large[len(large)-1]
+		small, large = small[:len(small)-1], large[:len(large)-1]
+
+		rw.prob[l] = scaled[l]
+		rw.alias[l] = g
+		if scaled[g] += scaled[l] - 1; scaled[g] < 1 {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+	// the rest are 1 up to the rounding errors.
+	for _, i := range large {
+		rw.prob[i] = 1
+	}
+	for _, i := range small {
+		rw.prob[i] = 1
+	}
+}
+
+func (rw *RandomWeighted[T]) invalidate() {
+	rw.shares = nil
+	rw.prob = nil
+	rw.alias = nil
+}
+
+// floorShares returns the probabilities of the items with the minimum shares applied,
+// or nil if no minimum share is set.
+func (rw *RandomWeighted[T]) floorShares() []float64 {
+	if rw.shares != nil {
+		return rw.shares
+	}
+
+	floors := make([]float64, len(rw.items))
+	var total float64
+	for i, item := range rw.items {
+		floors[i] = rw.minShare
+		if item.minShare > 0 {
+			floors[i] = item.minShare
+		}
+		total += floors[i]
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	shares := make([]float64, len(rw.items))
+	if total >= 1 {
+		for i := range floors {
+			shares[i] = floors[i] / total
+		}
+		rw.shares = shares
+		return shares
+	}
+
+	// the items whose weighted shares fall below their floors are fixed at the floors,
+	// repeat until the renormalized shares of the others are all above their floors.
+	fixed := make([]bool, len(rw.items))
+	for {
+		remaining := 1.0
+		var weights float64
+		for i, item := range rw.items {
+			if fixed[i] {
+				remaining -= floors[i]
+			} else {
+				weights += float64(item.weight)
+			}
+		}
+
+		changed := false
+		for i, item := range rw.items {
+			if fixed[i] {
+				shares[i] = floors[i]
+				continue
+			}
+			shares[i] = 0
+			if weights > 0 {
+				shares[i] = remaining * float64(item.weight) / weights
+			}
+			if shares[i] < floors[i] {
+				fixed[i] = true
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	rw.shares = shares
+	return shares
+}
+
+// Update sets the weight of the first item with the same identity as item, it reports whether the item is found.
+// The weights are updated in place without rebuilding the items.
+func (rw *RandomWeighted[T]) Update(item T, weight int) bool {
+	if i := rw.index(item); i >= 0 {
+		rw.update(i, weight)
+		return true
+	}
+	return false
+}
+
+// Remove removes the first item with the same identity as item, it reports whether the item is found.
+func (rw *RandomWeighted[T]) Remove(item T) bool {
+	i := rw.index(item)
+	if i < 0 {
+		return false
+	}
+	rw.sum -= rw.items[i].weight
+	rw.items = append(rw.items[:i], rw.items[i+1:]...)
+	rw.invalidate()
+	return true
+}
+
+func (rw *RandomWeighted[T]) index(item T) int {
+	id := identity(item)
+	for i, ri := range rw.items {
+		if identity(ri.item) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (rw *RandomWeighted[T]) update(i int, weight int) {
+	if ri := rw.items[i]; ri.weight != weight {
+		rw.sum += weight - ri.weight
+		ri.weight = weight
+		rw.invalidate()
+	}
 }
 
 func (rw *RandomWeighted[T]) Reset() {
 	rw.items = nil
 	rw.sum = 0
+	rw.invalidate()
+}
+
+// FenwickWeighted is a weighted random sampler backed by a Fenwick tree (binary indexed tree),
+// adding an item, updating the weight of an item and sampling are all O(log n),
+// so the individual weight changes of a large set do not require a full rebuild.
+type FenwickWeighted[T any] struct {
+	items   []T
+	weights []int
+	// tree is 1-based, tree[i] is the sum of the weights in (i - lowbit(i), i].
+	tree []int
+	sum  int
+	r    *rand.Rand
+}
+
+func NewFenwickWeighted[T any]() *FenwickWeighted[T] {
+	return &FenwickWeighted[T]{
+		tree: []int{0},
+		r:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (fw *FenwickWeighted[T]) Add(item T, weight int) {
+	if weight < 0 {
+		weight = 0
+	}
+	fw.items = append(fw.items, item)
+	fw.weights = append(fw.weights, weight)
+
+	i := len(fw.items)
+	fw.tree = append(fw.tree, weight+fw.prefix(i-1)-fw.prefix(i-i&-i))
+	fw.sum += weight
+}
+
+// Update updates the weight of the item at index i in order of addition.
+func (fw *FenwickWeighted[T]) Update(i int, weight int) {
+	if i < 0 || i >= len(fw.items) {
+		return
+	}
+	if weight < 0 {
+		weight = 0
+	}
+
+	delta := weight - fw.weights[i]
+	if delta == 0 {
+		return
+	}
+	fw.weights[i] = weight
+	fw.sum += delta
+	for j := i + 1; j < len(fw.tree); j += j & -j {
+		fw.tree[j] += delta
+	}
+}
+
+// Weight returns the weight of the item at index i.
+func (fw *FenwickWeighted[T]) Weight(i int) int {
+	if i < 0 || i >= len(fw.weights) {
+		return 0
+	}
+	return fw.weights[i]
+}
+
+func (fw *FenwickWeighted[T]) Len() int {
+	return len(fw.items)
+}
+
+func (fw *FenwickWeighted[T]) Next() (v T) {
+	if len(fw.items) == 0 || fw.sum <= 0 {
+		return
+	}
+
+	// find the first index whose prefix sum exceeds x by descending the tree.
+	x := fw.r.Intn(fw.sum)
+	pos := 0
+	for step := highBit(len(fw.items)); step > 0; step >>= 1 {
+		if next := pos + step; next < len(fw.tree) && fw.tree[next] <= x {
+			pos = next
+			x -= fw.tree[next]
+		}
+	}
+	return fw.items[pos]
+}
+
+func (fw *FenwickWeighted[T]) Reset() {
+	fw.items = nil
+	fw.weights = nil
+	fw.tree = fw.tree[:1]
+	fw.sum = 0
+}
+
+// prefix returns the sum of the weights of the first n items.
+func (fw *FenwickWeighted[T]) prefix(n int) (sum int) {
+	for ; n > 0; n -= n & -n {
+		sum += fw.tree[n]
+	}
+	return
+}
+
+func highBit(n int) int {
+	b := 1
+	for b <= n>>1 {
+		b <<= 1
+	}
+	return b
 }
@@ -0,0 +1,150 @@
+package selector
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestFenwickWeighted(t *testing.T) {
+	fw := NewFenwickWeighted[string]()
+	fw.r = rand.New(rand.NewSource(1))
+
+	weights := []int{1, 0, 3, 6}
+	for i, w := range weights {
+		fw.Add(strconv.Itoa(i), w)
+	}
+	fw.Update(1, 10)
+	fw.Update(3, 0)
+
+	const n = 100000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[fw.Next()]++
+	}
+
+	expected := map[string]float64{"0": 1.0 / 14, "1": 10.0 / 14, "2": 3.0 / 14, "3": 0}
+	for item, p := range expected {
+		if got := float64(counts[item]) / n; got < p-0.01 || got > p+0.01 {
+			t.Errorf("item %s: got share %.4f, expected %.4f", item, got, p)
+		}
+	}
+}
+
+// chiSquare returns the chi-square statistic of the counts against the expected probabilities of n draws.
+func chiSquare(counts []int, probs []float64, n int) float64 {
+	var x float64
+	for i, p := range probs {
+		if p == 0 {
+			continue
+		}
+		expected := p * float64(n)
+		d := float64(counts[i]) - expected
+		x += d * d / expected
+	}
+	return x
+}
+
+func TestRandomWeightedDistribution(t *testing.T) {
+	const n = 200000
+
+	tests := []struct {
+		name     string
+		weights  []int
+		minShare float64
+		probs    []float64
+		// critical is the chi-square critical value at p = 0.001 of the non-zero probabilities.
+		critical float64
+	}{
+		{
+			name:     "weights",
+			weights:  []int{1, 2, 3, 4, 10},
+			probs:    []float64{0.05, 0.1, 0.15, 0.2, 0.5},
+			critical: 18.47,
+		},
+		{
+			name:     "zero weight",
+			weights:  []int{5, 0, 15},
+			probs:    []float64{0.25, 0, 0.75},
+			critical: 10.83,
+		},
+		{
+			name:     "min share",
+			weights:  []int{1, 1, 98},
+			minShare: 0.1,
+			probs:    []float64{0.1, 0.1, 0.8},
+			critical: 13.82,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := NewRandomWeighted[int]()
+			rw.r = rand.New(rand.NewSource(1))
+			rw.SetMinShare(tt.minShare)
+			for i, w := range tt.weights {
+				rw.Add(i, w)
+			}
+
+			counts := make([]int, len(tt.weights))
+			for i := 0; i < n; i++ {
+				counts[rw.Next()]++
+			}
+
+			for i, p := range tt.probs {
+				if p == 0 && counts[i] > 0 {
+					t.Errorf("item %d: got %d selections, expected none", i, counts[i])
+				}
+			}
+			if x := chiSquare(counts, tt.probs, n); x > tt.critical {
+				t.Errorf("got chi-square %.2f for counts %v, expected at most %.2f", x, counts, tt.critical)
+			}
+		})
+	}
+}
+
+const benchWeightedNodes = 10000
+
+// the weights of a few nodes change between the selections.
+func benchWeights(b *testing.B) ([]int, *rand.Rand) {
+	b.Helper()
+	r := rand.New(rand.NewSource(1))
+	weights := make([]int, benchWeightedNodes)
+	for i := range weights {
+		weights[i] = r.Intn(100) + 1
+	}
+	return weights, r
+}
+
+func BenchmarkRandomWeightedRebuild(b *testing.B) {
+	weights, r := benchWeights(b)
+	rw := NewRandomWeighted[int]()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			weights[r.Intn(benchWeightedNodes)] = r.Intn(100) + 1
+		}
+		rw.Reset()
+		for j, w := range weights {
+			rw.Add(j, w)
+		}
+		rw.Next()
+	}
+}
+
+func BenchmarkFenwickWeightedUpdate(b *testing.B) {
+	weights, r := benchWeights(b)
+	fw := NewFenwickWeighted[int]()
+	for j, w := range weights {
+		fw.Add(j, w)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			fw.Update(r.Intn(benchWeightedNodes), r.Intn(100)+1)
+		}
+		fw.Next()
+	}
+}